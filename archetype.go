@@ -0,0 +1,347 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// archetypeStorage contains the tables of a scene using ArchetypeStorage. Entities
+// without components are not stored in any archetype.
+type archetypeStorage struct {
+	archetypes []*archetype
+	byKey      map[string]*archetype
+	rootEdges  map[uint32]*archetype // archetypes with a single component type
+}
+
+// archetype is a table of all entities with the same set of component types. Row i of
+// every column belongs to entities[i].
+type archetype struct {
+	ids      []uint32 // sorted component IDs
+	entities []Entity
+	columns  []column // indexed by component ID, nil for types not in the archetype
+
+	addEdges    map[uint32]*archetype
+	removeEdges map[uint32]*archetype
+}
+
+// column is a type erased column of components in an archetype.
+type column interface {
+	// moveRow appends the component in row to the column to, which must have the same type.
+	moveRow(row uint32, to column)
+	// removeRow removes the component in row, by moving the last component into its place.
+	removeRow(row uint32)
+}
+
+type columnOf[T any] struct {
+	components []Component[T]
+}
+
+// archetypePool implements typedPool for a scene using ArchetypeStorage.
+type archetypePool[T any] struct {
+	id      uint32
+	scene   *Scene
+	hooks   componentHooks[T]
+	removed removalLog
+}
+
+// archetypePoolInterface is implemented by the pools of scenes using ArchetypeStorage.
+type archetypePoolInterface interface {
+	newColumn() column
+	// removing is called before the entity in the row of the archetype is removed. The
+	// returned function, which may be nil, is called after the removal.
+	removing(a *archetype, row uint32) func()
+}
+
+func newArchetypeStorage() *archetypeStorage {
+	return &archetypeStorage{
+		byKey:     make(map[string]*archetype),
+		rootEdges: make(map[uint32]*archetype),
+	}
+}
+
+// with returns the archetype with the component types of from and the component type id.
+// from may be nil.
+func (storage *archetypeStorage) with(scene *Scene, from *archetype, id uint32) *archetype {
+	if from == nil {
+		if to, ok := storage.rootEdges[id]; ok {
+			return to
+		}
+		to := storage.get(scene, []uint32{id})
+		storage.rootEdges[id] = to
+		return to
+	}
+	if to, ok := from.addEdges[id]; ok {
+		return to
+	}
+	ids := make([]uint32, len(from.ids), len(from.ids)+1)
+	copy(ids, from.ids)
+	ids = append(ids, id)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	to := storage.get(scene, ids)
+	from.addEdges[id] = to
+	return to
+}
+
+// without returns the archetype with the component types of from except id, or nil if
+// from has no other component types.
+func (storage *archetypeStorage) without(scene *Scene, from *archetype, id uint32) *archetype {
+	if to, ok := from.removeEdges[id]; ok {
+		return to
+	}
+	ids := make([]uint32, 0, len(from.ids))
+	for _, other := range from.ids {
+		if other != id {
+			ids = append(ids, other)
+		}
+	}
+	var to *archetype
+	if len(ids) > 0 {
+		to = storage.get(scene, ids)
+	}
+	from.removeEdges[id] = to
+	return to
+}
+
+// get returns the archetype with the sorted component IDs, and creates it if needed.
+func (storage *archetypeStorage) get(scene *Scene, ids []uint32) *archetype {
+	keyBytes := make([]byte, 4*len(ids))
+	for i, id := range ids {
+		binary.LittleEndian.PutUint32(keyBytes[4*i:], id)
+	}
+	key := string(keyBytes)
+	if a, ok := storage.byKey[key]; ok {
+		return a
+	}
+
+	a := &archetype{
+		ids:         ids,
+		columns:     make([]column, ids[len(ids)-1]+1),
+		addEdges:    make(map[uint32]*archetype),
+		removeEdges: make(map[uint32]*archetype),
+	}
+	for _, id := range ids {
+		a.columns[id] = scene.componentPools[id].(archetypePoolInterface).newColumn()
+	}
+	storage.byKey[key] = a
+	storage.archetypes = append(storage.archetypes, a)
+	return a
+}
+
+// move moves the entity and the components it shares with the archetype to, to the end
+// of the archetype to. Components not in to are dropped. to may be nil. The entity is
+// passed by value, as a pointer could point into the archetype the entity is moved from.
+func (storage *archetypeStorage) move(scene *Scene, entity Entity, to *archetype) {
+	record := &scene.entities[entity.id.Index()]
+	from := record.archetype
+	if from == to {
+		return
+	}
+
+	if from != nil {
+		for _, id := range from.ids {
+			if toColumn := to.column(id); toColumn != nil {
+				from.columns[id].moveRow(record.row, toColumn)
+			}
+		}
+		from.removeRow(scene, record.row)
+	}
+
+	record.archetype = to
+	if to != nil {
+		to.entities = append(to.entities, entity)
+		record.row = uint32(len(to.entities) - 1)
+	}
+}
+
+// removeEntity removes the entity and all its components.
+func (storage *archetypeStorage) removeEntity(scene *Scene, entity Entity) {
+	record := &scene.entities[entity.id.Index()]
+	a := record.archetype
+	if a == nil {
+		return
+	}
+
+	var removed []func()
+	for _, id := range a.ids {
+		if fn := scene.componentPools[id].(archetypePoolInterface).removing(a, record.row); fn != nil {
+			removed = append(removed, fn)
+		}
+	}
+	storage.move(scene, entity, nil)
+	record.signature.reset()
+	for _, fn := range removed {
+		fn()
+	}
+}
+
+// query calls visit for every entity in the archetypes with all the component types ids.
+func (storage *archetypeStorage) query(ids []uint32, visit func(entity *Entity)) {
+	for _, a := range storage.archetypes {
+		if !a.has(ids) {
+			continue
+		}
+		for row := 0; row < len(a.entities); row++ {
+			visit(&a.entities[row])
+		}
+	}
+}
+
+// column returns the column of component type id, or nil if the archetype does not
+// have the type. a may be nil.
+func (a *archetype) column(id uint32) column {
+	if a == nil || id >= uint32(len(a.columns)) {
+		return nil
+	}
+	return a.columns[id]
+}
+
+// columnIDs returns the IDs in ids of the component types stored in archetype columns,
+// leaving out tags, which are only stored in the entity signatures.
+func columnIDs(scene *Scene, ids []uint32) []uint32 {
+	var columns []uint32
+	for _, id := range ids {
+		if _, ok := scene.componentPools[id].(archetypePoolInterface); ok {
+			columns = append(columns, id)
+		}
+	}
+	return columns
+}
+
+func (a *archetype) has(ids []uint32) bool {
+	for _, id := range ids {
+		if a.column(id) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *archetype) removeRow(scene *Scene, row uint32) {
+	for _, id := range a.ids {
+		a.columns[id].removeRow(row)
+	}
+
+	last := uint32(len(a.entities) - 1)
+	a.entities[row] = a.entities[last]
+	a.entities = a.entities[:last]
+	if row < last {
+		scene.entities[a.entities[row].id.Index()].row = row
+	}
+}
+
+func (c *columnOf[T]) moveRow(row uint32, to column) {
+	toColumn := to.(*columnOf[T])
+	toColumn.components = append(toColumn.components, c.components[row])
+}
+
+func (c *columnOf[T]) removeRow(row uint32) {
+	last := len(c.components) - 1
+	c.components[row] = c.components[last]
+	c.components = c.components[:last]
+}
+
+func (p *archetypePool[T]) componentID() uint32 {
+	return p.id
+}
+
+func (p *archetypePool[T]) componentHooks() *componentHooks[T] {
+	return &p.hooks
+}
+
+func (p *archetypePool[T]) newColumn() column {
+	return &columnOf[T]{}
+}
+
+func (p *archetypePool[T]) removals() *removalLog {
+	return &p.removed
+}
+
+func (p *archetypePool[T]) capture(entity *Entity) PrefabComponent {
+	return capture[T](p, entity)
+}
+
+func (p *archetypePool[T]) removing(a *archetype, row uint32) func() {
+	p.removed.add(a.entities[row])
+	if len(p.hooks.onRemove) == 0 {
+		return nil
+	}
+	removed := a.columns[p.id].(*columnOf[T]).components[row]
+	return func() {
+		p.hooks.removed(&removed)
+	}
+}
+
+func (p *archetypePool[T]) add(entity *Entity, data *T) {
+	record := &p.scene.entities[entity.id.Index()]
+	if c, ok := record.archetype.column(p.id).(*columnOf[T]); ok {
+		component := &c.components[record.row]
+		component.set(data)
+		p.hooks.set(component)
+		return
+	}
+
+	added := *entity
+	storage := p.scene.archetypes
+	to := storage.with(p.scene, record.archetype, p.id)
+	storage.move(p.scene, added, to)
+	toColumn := to.columns[p.id].(*columnOf[T])
+	toColumn.components = append(toColumn.components, newComponent(&added, data))
+	record.signature.set(p.id)
+	p.hooks.added(&toColumn.components[len(toColumn.components)-1])
+}
+
+func (p *archetypePool[T]) get(entity *Entity) *T {
+	if component := p.component(entity); component != nil {
+		return &component.component
+	}
+	return nil
+}
+
+func (p *archetypePool[T]) component(entity *Entity) *Component[T] {
+	record := &p.scene.entities[entity.id.Index()]
+	c, ok := record.archetype.column(p.id).(*columnOf[T])
+	if !ok {
+		return nil
+	}
+	return &c.components[record.row]
+}
+
+func (p *archetypePool[T]) chunks(ids []uint32) [][]Component[T] {
+	var chunks [][]Component[T]
+	ids = columnIDs(p.scene, ids)
+	for _, a := range p.scene.archetypes.archetypes {
+		if a.has(ids) && len(a.entities) > 0 {
+			chunks = append(chunks, a.columns[p.id].(*columnOf[T]).components)
+		}
+	}
+	return chunks
+}
+
+func (p *archetypePool[T]) remove(entity *Entity) bool {
+	record := &p.scene.entities[entity.id.Index()]
+	c, ok := record.archetype.column(p.id).(*columnOf[T])
+	if !ok {
+		return false
+	}
+	removed := c.components[record.row]
+	storage := p.scene.archetypes
+	storage.move(p.scene, *entity, storage.without(p.scene, record.archetype, p.id))
+	record.signature.clear(p.id)
+	p.removed.add(removed.entity)
+	p.hooks.removed(&removed)
+	return true
+}
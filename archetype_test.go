@@ -0,0 +1,166 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+func TestArchetypeAddGetRemoveComponent(t *testing.T) {
+	scene := ecs.NewScene(ecs.ArchetypeStorage)
+	entities := make([]ecs.Entity, 10)
+	for n := 0; n < 10; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{x: float64(n)})
+		if n%2 == 0 {
+			ecs.AddComponent(&entities[n], &velocity{x: float64(n)})
+		}
+		if n%3 == 0 {
+			ecs.AddComponent(&entities[n], &health{hp: n})
+		}
+	}
+
+	for n := 0; n < 10; n += 4 {
+		err := ecs.RemoveComponent[position](&entities[n])
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	}
+	ecs.AddComponent(&entities[1], &position{x: 100})
+	entities[5].Remove()
+
+	for n := 0; n < 10; n++ {
+		p, err := ecs.GetComponent[position](&entities[n])
+		switch {
+		case n == 5 || n%4 == 0:
+			t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		case n == 1:
+			t.Run("Expected correct result", subx.Test(subx.Value(p.x), subx.CompareEqual(100.0)))
+		default:
+			t.Run("Expected correct result", subx.Test(subx.Value(p.x), subx.CompareEqual(float64(n))))
+		}
+
+		v, err := ecs.GetComponent[velocity](&entities[n])
+		if n%2 == 0 {
+			t.Run("Expected correct result", subx.Test(subx.Value(v.x), subx.CompareEqual(float64(n))))
+		} else {
+			t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		}
+
+		h, err := ecs.GetComponent[health](&entities[n])
+		if n%3 == 0 {
+			t.Run("Expected correct result", subx.Test(subx.Value(h.hp), subx.CompareEqual(n)))
+		} else {
+			t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		}
+	}
+}
+
+func TestArchetypeQuery(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		entities := make([]ecs.Entity, 10)
+		for n := 0; n < 10; n++ {
+			entities[n] = scene.NewEntity()
+			ecs.AddComponent(&entities[n], &position{})
+			ecs.AddComponent(&entities[n], &velocity{x: 1})
+			if n%2 == 0 {
+				ecs.AddComponent(&entities[n], &frozen{})
+			}
+		}
+		entities[1].Remove()
+
+		count := 0
+		ecs.Query2(scene, func(entity *ecs.Entity, p *position, v *velocity) {
+			p.x += v.x
+			count++
+		}, ecs.Without[frozen]())
+		t.Run("Expected correct result", subx.Test(subx.Value(count), subx.CompareEqual(4)))
+
+		for n := 3; n < 10; n += 2 {
+			p, _ := ecs.GetComponent[position](&entities[n])
+			t.Run("Expected correct result", subx.Test(subx.Value(p.x), subx.CompareEqual(1.0)))
+		}
+	}
+}
+
+func TestArchetypeAllComponentsPanics(t *testing.T) {
+	scene := ecs.NewScene(ecs.ArchetypeStorage)
+	defer func() {
+		t.Run("Expected correct result", subx.Test(subx.Value(recover()), subx.CompareNotEqual[any](nil)))
+	}()
+	ecs.AllComponents[position](scene)
+}
+
+func benchmarkQuery3(b *testing.B, storage ecs.Storage) {
+	scene := ecs.NewScene(storage)
+	for i := 0; i < 1000; i++ {
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		ecs.AddComponent(&entity, &velocity{x: 1, y: 1})
+		if i%2 == 0 {
+			ecs.AddComponent(&entity, &health{})
+		}
+		if i%3 == 0 {
+			ecs.AddComponent(&entity, &frozen{})
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ecs.Query3(scene, func(entity *ecs.Entity, p *position, v *velocity, h *health) {
+			p.x += v.x
+			p.y += v.y
+			h.hp++
+		})
+	}
+}
+
+func BenchmarkQuery3Pool(b *testing.B) {
+	benchmarkQuery3(b, ecs.PoolStorage)
+}
+
+func BenchmarkQuery3Archetype(b *testing.B) {
+	benchmarkQuery3(b, ecs.ArchetypeStorage)
+}
+
+func benchmarkAddRemoveComponent(b *testing.B, storage ecs.Storage) {
+	scene := ecs.NewScene(storage)
+	entities := make([]ecs.Entity, 100)
+	for n := 0; n < 100; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{})
+		ecs.AddComponent(&entities[n], &velocity{})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n := 0; n < 100; n++ {
+			ecs.AddComponent(&entities[n], &health{})
+		}
+		for n := 0; n < 100; n++ {
+			ecs.RemoveComponent[health](&entities[n])
+		}
+	}
+}
+
+func BenchmarkAddRemoveComponentPool(b *testing.B) {
+	benchmarkAddRemoveComponent(b, ecs.PoolStorage)
+}
+
+func BenchmarkAddRemoveComponentArchetype(b *testing.B) {
+	benchmarkAddRemoveComponent(b, ecs.ArchetypeStorage)
+}
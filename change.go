@@ -0,0 +1,157 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+// Changes are tracked with ticks. The scene tick is increased before each system is
+// updated, and components store the tick when they were added and last changed. A
+// change is new to a system if its tick is after the last update of the system.
+
+// currentTick returns the tick stored on components added or changed now. Ticks start
+// at 1, so that all changes are new to systems which have not been updated yet.
+func (scene *Scene) currentTick() uint32 {
+	return scene.tick + 1
+}
+
+type addedFilter[T any] struct {
+	system SystemInterface
+}
+
+// Added returns a filter only passing entities which got a component of type T after
+// the last update of the system.
+func Added[T any](system SystemInterface) Filter {
+	return addedFilter[T]{system}
+}
+
+func (filter addedFilter[T]) matcher(scene *Scene) func(entity *Entity) bool {
+	componentPool, ok := findPool[T](scene)
+	if !ok {
+		return func(entity *Entity) bool { return false }
+	}
+	lastRun := filter.system.base().lastRun
+	return func(entity *Entity) bool {
+		component := componentPool.component(entity)
+		return component != nil && component.added > lastRun
+	}
+}
+
+type changedFilter[T any] struct {
+	system SystemInterface
+}
+
+// Changed returns a filter only passing entities with a component of type T which has
+// been added or changed after the last update of the system. Components are changed by
+// AddComponent, Mut and MarkChanged.
+func Changed[T any](system SystemInterface) Filter {
+	return changedFilter[T]{system}
+}
+
+func (filter changedFilter[T]) matcher(scene *Scene) func(entity *Entity) bool {
+	componentPool, ok := findPool[T](scene)
+	if !ok {
+		return func(entity *Entity) bool { return false }
+	}
+	lastRun := filter.system.base().lastRun
+	return func(entity *Entity) bool {
+		component := componentPool.component(entity)
+		return component != nil && component.changed > lastRun
+	}
+}
+
+// Mut returns a pointer to the component of type T from the entity, and marks the
+// component as changed. An error is returned if the component does not exist or if the
+// entity is deleted.
+func Mut[T any](entity *Entity) (*T, error) {
+	if !entity.alive() {
+		return nil, ErrEntityDead
+	}
+
+	var component *Component[T]
+	if componentPool, ok := findPool[T](entity.scene); ok {
+		component = componentPool.component(entity)
+	}
+	if component == nil {
+		return nil, newComponentError[T](entity)
+	}
+	component.MarkChanged()
+	return &component.component, nil
+}
+
+// MarkChanged marks the component of type T of the entity as changed. An error is
+// returned if the component does not exist or if the entity is deleted.
+func MarkChanged[T any](entity *Entity) error {
+	_, err := Mut[T](entity)
+	return err
+}
+
+// RemovedComponents returns the entities which have had a component of type T removed
+// after the last update of the system, including entities which have been removed.
+// Removals are recorded while the scene has systems, so a system which has not been
+// updated yet gets the removals since it was added.
+func RemovedComponents[T any](system SystemInterface) []Entity {
+	componentPool, ok := findPool[T](system.Scene())
+	if !ok {
+		// Nothing has been removed from a pool which does not exist.
+		return nil
+	}
+	return componentPool.removals().since(system.base().lastRun)
+}
+
+// trackRemovals starts recording the removals of all component types when a system is
+// added to the scene. Removals are not recorded in scenes which have never had systems,
+// where they would never be pruned.
+func (scene *Scene) trackRemovals() {
+	for _, pool := range scene.componentPools {
+		pool.removals().tracked = true
+	}
+}
+
+// removalLog records the entities which have had a component removed, for
+// RemovedComponents.
+type removalLog struct {
+	tracked bool // true when the scene has had systems
+	entries []removal
+}
+
+type removal struct {
+	entity Entity
+	tick   uint32
+}
+
+func (log *removalLog) add(entity Entity) {
+	if log.tracked {
+		log.entries = append(log.entries, removal{entity, entity.scene.currentTick()})
+	}
+}
+
+func (log *removalLog) since(tick uint32) []Entity {
+	var entities []Entity
+	for _, entry := range log.entries {
+		if entry.tick > tick {
+			entities = append(entities, entry.entity)
+		}
+	}
+	return entities
+}
+
+// prune forgets the removals at or before the tick.
+func (log *removalLog) prune(tick uint32) {
+	kept := log.entries[:0]
+	for _, entry := range log.entries {
+		if entry.tick > tick {
+			kept = append(kept, entry)
+		}
+	}
+	log.entries = kept
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type syncSystem struct {
+	ecs.System
+	added   int
+	changed int
+	removed int
+}
+
+func (sys *syncSystem) Update(dt float64) {
+	sys.added, sys.changed = 0, 0
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, p *position) {
+		sys.added++
+	}, ecs.Added[position](sys))
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, p *position) {
+		sys.changed++
+	}, ecs.Changed[position](sys))
+	sys.removed = len(ecs.RemovedComponents[position](sys))
+}
+
+type moveSystem struct {
+	ecs.System
+}
+
+func (sys *moveSystem) Update(dt float64) {
+	ecs.Query2(sys.Scene(), func(entity *ecs.Entity, p *position, v *velocity) {
+		p.x += v.x
+		ecs.MarkChanged[position](entity)
+	})
+}
+
+func TestChangeDetection(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		sync := &syncSystem{}
+		scene.AddSystem(sync)
+		scene.AddSystem(&moveSystem{})
+
+		entities := make([]ecs.Entity, 4)
+		for n := range entities {
+			entities[n] = scene.NewEntity()
+			ecs.AddComponent(&entities[n], &position{})
+		}
+		ecs.AddComponent(&entities[0], &velocity{x: 1})
+
+		scene.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.added), subx.CompareEqual(4)))
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.changed), subx.CompareEqual(4)))
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.removed), subx.CompareEqual(0)))
+
+		// Only the entity moved by moveSystem has changed.
+		scene.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.added), subx.CompareEqual(0)))
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.changed), subx.CompareEqual(1)))
+
+		p, _ := ecs.Mut[position](&entities[1])
+		p.x = 5
+		ecs.RemoveComponent[position](&entities[2])
+		entities[3].Remove()
+		scene.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.changed), subx.CompareEqual(2)))
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.removed), subx.CompareEqual(2)))
+
+		scene.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.changed), subx.CompareEqual(1)))
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.removed), subx.CompareEqual(0)))
+	}
+}
+
+func TestRemovedBeforeFirstUpdate(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		sync := &syncSystem{}
+		scene.AddSystem(sync)
+
+		// The system gets the removals made before it calls RemovedComponents the first
+		// time, both from pools created before and after the system was added.
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		ecs.RemoveComponent[position](&entity)
+		scene.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.removed), subx.CompareEqual(1)))
+
+		other := ecs.NewScene(storage)
+		entity = other.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		sync = &syncSystem{}
+		other.AddSystem(sync)
+		entity.Remove()
+		other.Update(0)
+		t.Run("Expected correct result", subx.Test(subx.Value(sync.removed), subx.CompareEqual(1)))
+	}
+}
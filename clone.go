@@ -0,0 +1,73 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "errors"
+
+// Clone creates a new entity with copies of all components and tags of the entity. The
+// clone gets the same parent as the entity, but the children and relations of the entity
+// are not copied. An error is returned if the entity is deleted or is in another scene, or if
+// systems are updated in parallel.
+func (scene *Scene) Clone(entity *Entity) (Entity, error) {
+	if !entity.alive() {
+		return Entity{}, ErrEntityDead
+	}
+	if entity.scene != scene {
+		return Entity{}, errors.New("ecs: entity is in another scene")
+	}
+	if err := scene.structuralError(); err != nil {
+		return Entity{}, err
+	}
+	// The entity may point into a pool, so it is copied before the pools are modified.
+	source := *entity
+	components := scene.captureComponents(&source)
+
+	clone := scene.NewEntity()
+	for _, component := range components {
+		component.add(&clone)
+	}
+	if parent, ok := source.Parent(); ok {
+		clone.SetParent(&parent)
+	}
+	return clone, nil
+}
+
+// MoveEntity moves the entity and its descendants from the src scene to the dst scene,
+// and returns the entity in the dst scene. The components are copied to the dst scene,
+// and the entity is removed from the src scene together with its relations. Entity IDs
+// stored in components are not changed. An error is returned if the entity is deleted or
+// is not in the src scene, or if systems are updated in parallel in either scene.
+func MoveEntity(src, dst *Scene, entity *Entity) (Entity, error) {
+	if !entity.alive() {
+		return Entity{}, ErrEntityDead
+	}
+	if entity.scene != src {
+		return Entity{}, errors.New("ecs: entity is not in the source scene")
+	}
+	if src == dst {
+		return *entity, nil
+	}
+	if err := src.structuralError(); err != nil {
+		return Entity{}, err
+	}
+	if err := dst.structuralError(); err != nil {
+		return Entity{}, err
+	}
+
+	prefab, _ := NewPrefabFromEntity(entity)
+	moved, _ := dst.Instantiate(prefab)
+	src.removeEntity(entity)
+	return moved, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+func TestClone(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		parent, entity := scene.NewEntity(), scene.NewEntity()
+		ecs.AddComponent(&entity, &position{x: 1, y: 2})
+		ecs.AddComponent(&entity, &loot{items: []string{"coin"}})
+		entity.SetParent(&parent)
+
+		clone, err := scene.Clone(&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(clone.ID()), subx.CompareNotEqual(entity.ID())))
+		pos, _ := ecs.GetComponent[position](&clone)
+		t.Run("Expected correct result", subx.Test(subx.Value(*pos), subx.CompareEqual(position{x: 1, y: 2})))
+		t.Run("Expected correct result", subx.Test(subx.Value(parent.Children()), subx.DeepEqual([]ecs.Entity{entity, clone})))
+
+		// The components are copied deeply.
+		l, _ := ecs.GetComponent[loot](&entity)
+		l.items[0] = "gem"
+		cloneLoot, _ := ecs.GetComponent[loot](&clone)
+		t.Run("Expected correct result", subx.Test(subx.Value(cloneLoot.items), subx.DeepEqual([]string{"coin"})))
+
+		other := ecs.NewScene(storage)
+		_, err = other.Clone(&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+}
+
+func TestMoveEntity(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		src, dst := ecs.NewScene(storage), ecs.NewScene(storage)
+		dst.NewEntity() // The moved entity gets a new ID.
+		player, weapon, npc := src.NewEntity(), src.NewEntity(), src.NewEntity()
+		ecs.AddComponent(&player, &health{hp: 3})
+		ecs.AddComponent(&weapon, &nameTag{Name: "sword"})
+		weapon.SetParent(&player)
+		ecs.AddRelation(&npc, &player, &likes{amount: 1})
+
+		moved, err := ecs.MoveEntity(src, dst, &player)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(src.Alive(player.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(src.Alive(weapon.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.Targets[likes](&npc))), subx.CompareEqual(0)))
+
+		hp, _ := ecs.GetComponent[health](&moved)
+		t.Run("Expected correct result", subx.Test(subx.Value(hp.hp), subx.CompareEqual(3)))
+		children := moved.Children()
+		t.Run("Expected correct result", subx.Test(subx.Value(len(children)), subx.CompareEqual(1)))
+		name, _ := ecs.GetComponent[nameTag](&children[0])
+		t.Run("Expected correct result", subx.Test(subx.Value(name.Name), subx.CompareEqual("sword")))
+
+		_, err = ecs.MoveEntity(src, dst, &player)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+}
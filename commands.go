@@ -0,0 +1,121 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "sync"
+
+// CommandBuffer records changes to entities and components, and applies them when the
+// buffer is flushed. Adding or removing components and entities while iterating over
+// components with AllComponents or a query is not safe, so the changes should be recorded
+// in a command buffer instead.
+//
+// Each system has its own command buffer, which is flushed after the system is updated.
+// The scene also has a command buffer, which is flushed with Scene.Flush.
+//
+// Commands can be recorded from several goroutines at the same time.
+type CommandBuffer struct {
+	scene    *Scene
+	mutex    sync.Mutex
+	commands []func()
+	pending  map[*Entity]bool // entities created by the buffer, not yet flushed
+}
+
+// NewCommandBuffer creates an empty command buffer for the scene.
+func NewCommandBuffer(scene *Scene) *CommandBuffer {
+	return &CommandBuffer{scene: scene}
+}
+
+// NewEntity records the creation of a new entity. The returned entity is set when the
+// buffer is flushed, but can be passed to other commands in the buffer before that.
+func (buffer *CommandBuffer) NewEntity() *Entity {
+	entity := &Entity{}
+	buffer.mutex.Lock()
+	if buffer.pending == nil {
+		buffer.pending = make(map[*Entity]bool)
+	}
+	buffer.pending[entity] = true
+	buffer.mutex.Unlock()
+
+	buffer.record(func() {
+		*entity = buffer.scene.NewEntity()
+	})
+	return entity
+}
+
+// RemoveEntity records the removal of the entity, all its components and all its
+// descendants.
+func (buffer *CommandBuffer) RemoveEntity(entity *Entity) {
+	resolve := buffer.resolve(entity)
+	buffer.record(func() {
+		resolve().Remove()
+	})
+}
+
+// Flush applies all the recorded commands in the order they were recorded. Commands
+// for entities which have been removed are ignored.
+func (buffer *CommandBuffer) Flush() {
+	for {
+		buffer.mutex.Lock()
+		commands := buffer.commands
+		buffer.commands = nil
+		if len(commands) == 0 {
+			buffer.pending = nil
+			buffer.mutex.Unlock()
+			return
+		}
+		buffer.mutex.Unlock()
+
+		for _, command := range commands {
+			command()
+		}
+	}
+}
+
+func (buffer *CommandBuffer) record(command func()) {
+	buffer.mutex.Lock()
+	buffer.commands = append(buffer.commands, command)
+	buffer.mutex.Unlock()
+}
+
+// resolve returns a function returning the entity when the buffer is flushed. Entities
+// created by the buffer are not set before the flush, while other entities are copied,
+// since the pointer may point into a pool which changes before the flush.
+func (buffer *CommandBuffer) resolve(entity *Entity) func() *Entity {
+	buffer.mutex.Lock()
+	pending := buffer.pending[entity]
+	buffer.mutex.Unlock()
+	if pending {
+		return func() *Entity { return entity }
+	}
+	copied := *entity
+	return func() *Entity { return &copied }
+}
+
+// DeferAddComponent records adding a copy of the component to the entity.
+func DeferAddComponent[T any](buffer *CommandBuffer, entity *Entity, component *T) {
+	resolve := buffer.resolve(entity)
+	copied := *component
+	buffer.record(func() {
+		AddComponent(resolve(), &copied)
+	})
+}
+
+// DeferRemoveComponent records removing the component of type T from the entity.
+func DeferRemoveComponent[T any](buffer *CommandBuffer, entity *Entity) {
+	resolve := buffer.resolve(entity)
+	buffer.record(func() {
+		RemoveComponent[T](resolve())
+	})
+}
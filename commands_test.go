@@ -0,0 +1,93 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+func TestCommandBufferRemoveWhileIterating(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		for n := 0; n < 10; n++ {
+			entity := scene.NewEntity()
+			ecs.AddComponent(&entity, &health{hp: n})
+		}
+
+		visited := 0
+		ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+			visited++
+			if h.hp%2 == 0 {
+				scene.Commands().RemoveEntity(entity)
+			} else {
+				ecs.DeferAddComponent(scene.Commands(), entity, &frozen{})
+			}
+		})
+		scene.Flush()
+		t.Run("Expected correct result", subx.Test(subx.Value(visited), subx.CompareEqual(10)))
+
+		remaining := 0
+		ecs.Query2(scene, func(entity *ecs.Entity, h *health, f *frozen) {
+			remaining++
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(remaining), subx.CompareEqual(5)))
+	}
+}
+
+func TestCommandBufferNewEntity(t *testing.T) {
+	scene := ecs.Scene{}
+	buffer := ecs.NewCommandBuffer(&scene)
+
+	entity := buffer.NewEntity()
+	ecs.DeferAddComponent(buffer, entity, &health{hp: 7})
+	ecs.DeferAddComponent(buffer, entity, &frozen{})
+	ecs.DeferRemoveComponent[frozen](buffer, entity)
+
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(entity.ID())), subx.CompareEqual(false)))
+	buffer.Flush()
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(entity.ID())), subx.CompareEqual(true)))
+
+	h, err := ecs.GetComponent[health](entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	t.Run("Expected correct result", subx.Test(subx.Value(h.hp), subx.CompareEqual(7)))
+	_, err = ecs.GetComponent[frozen](entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+}
+
+type spawnSystem struct {
+	ecs.System
+}
+
+func (sys *spawnSystem) Update(dt float64) {
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, h *health) {
+		spawned := sys.Commands().NewEntity()
+		ecs.DeferAddComponent(sys.Commands(), spawned, &health{hp: h.hp + 1})
+	})
+}
+
+func TestCommandBufferSystemFlush(t *testing.T) {
+	scene := ecs.Scene{}
+	entity := scene.NewEntity()
+	ecs.AddComponent(&entity, &health{})
+	scene.AddSystem(&spawnSystem{})
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.AllComponents[health](&scene))), subx.CompareEqual(2)))
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.AllComponents[health](&scene))), subx.CompareEqual(4)))
+}
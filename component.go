@@ -17,16 +17,34 @@ package ecs
 // Component is a component with its entity.
 // A slice of "Component"s is returned in the AllComponents function.
 type Component[T any] struct {
-	entity    *Entity
+	entity    Entity
 	component T
+	added     uint32 // tick when the component was added
+	changed   uint32 // tick when the component was last changed
+}
+
+func newComponent[T any](entity *Entity, data *T) Component[T] {
+	tick := entity.scene.currentTick()
+	return Component[T]{*entity, *data, tick, tick}
 }
 
 // Entity returns the entity of the component.
 func (component *Component[T]) Entity() *Entity {
-	return component.entity
+	return &component.entity
 }
 
 // Component returns the component data.
 func (component *Component[T]) Component() *T {
 	return &component.component
 }
+
+// MarkChanged marks the component as changed, so that it is visited by queries with the
+// Changed filter.
+func (component *Component[T]) MarkChanged() {
+	component.changed = component.entity.scene.currentTick()
+}
+
+func (component *Component[T]) set(data *T) {
+	component.component = *data
+	component.MarkChanged()
+}
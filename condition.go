@@ -0,0 +1,65 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+// Condition decides if a system should be updated. It is called by Scene.Update right
+// before the system would be updated, on the goroutine calling Scene.Update.
+type Condition func(system SystemInterface) bool
+
+// RunIf makes the system only be updated when all the conditions are true.
+func RunIf(conditions ...Condition) SystemOption {
+	return func(entry *systemEntry) {
+		entry.conditions = append(entry.conditions, conditions...)
+	}
+}
+
+// And returns a condition which is true if all the conditions are true.
+func And(conditions ...Condition) Condition {
+	return func(system SystemInterface) bool {
+		for _, condition := range conditions {
+			if !condition(system) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a condition which is true if any of the conditions are true.
+func Or(conditions ...Condition) Condition {
+	return func(system SystemInterface) bool {
+		for _, condition := range conditions {
+			if condition(system) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a condition which is true if the condition is false.
+func Not(condition Condition) Condition {
+	return func(system SystemInterface) bool {
+		return !condition(system)
+	}
+}
+
+// EveryN returns a condition which is true every n-th Scene.Update, starting with the
+// first. Systems in StageFixedUpdate are updated every fixed step during those updates.
+func EveryN(n int) Condition {
+	return func(system SystemInterface) bool {
+		return n <= 1 || system.Scene().frame%uint64(n) == 0
+	}
+}
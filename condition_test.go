@@ -0,0 +1,60 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+func TestRunIf(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+	paused := false
+	isPaused := func(system ecs.SystemInterface) bool { return paused }
+
+	scene.AddSystem(&orderSystem{name: "game", order: &order}, ecs.RunIf(ecs.Not(isPaused)))
+	scene.AddSystem(&orderSystem{name: "menu", order: &order}, ecs.RunIf(isPaused))
+	scene.AddSystem(&orderSystem{name: "render", order: &order})
+
+	scene.Update(0)
+	paused = true
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"game", "render", "menu", "render"})))
+}
+
+func TestEveryN(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	scene.AddSystem(&orderSystem{name: "a", order: &order}, ecs.RunIf(ecs.EveryN(3)))
+	for i := 0; i < 7; i++ {
+		scene.Update(0)
+	}
+	t.Run("Expected correct result", subx.Test(subx.Value(len(order)), subx.CompareEqual(3)))
+}
+
+func TestConditionAndOr(t *testing.T) {
+	yes := func(system ecs.SystemInterface) bool { return true }
+	no := func(system ecs.SystemInterface) bool { return false }
+	sys := &system1{}
+
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.And(yes, yes)(sys)), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.And(yes, no)(sys)), subx.CompareEqual(false)))
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.Or(no, yes)(sys)), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.Or(no, no)(sys)), subx.CompareEqual(false)))
+}
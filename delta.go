@@ -0,0 +1,653 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Change is the kind of a change in a Delta.
+type Change int
+
+const (
+	ChangeAdded Change = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func (change Change) String() string {
+	switch change {
+	case ChangeAdded:
+		return "Added"
+	case ChangeRemoved:
+		return "Removed"
+	case ChangeModified:
+		return "Modified"
+	}
+	return fmt.Sprintf("Change(%d)", int(change))
+}
+
+// ComponentChange is a component which has been added, removed or modified.
+type ComponentChange struct {
+	Change Change
+	Entity EntityID
+	Type   string // the name the type is registered with
+	Value  any    // the new value of type T, nil when removed
+}
+
+// RelationChange is a relation which has been added, removed or modified.
+type RelationChange struct {
+	Change Change
+	Source EntityID
+	Target EntityID
+	Type   string // the name the type is registered with
+	Value  any    // the new value of type R, nil when removed
+}
+
+// ResourceChange is a resource which has been added, removed or modified.
+type ResourceChange struct {
+	Change Change
+	Type   string // the name the type is registered with
+	Value  any    // the new value of type T, nil when removed
+}
+
+// ChildrenChange is the new children of an entity whose children have changed.
+type ChildrenChange struct {
+	Entity   EntityID
+	Children []EntityID
+}
+
+// Delta is the difference between two snapshots, created by Diff. Components,
+// relations and children of destroyed entities are not included, since they are removed
+// with the entity.
+type Delta struct {
+	Created    []EntityID
+	Destroyed  []EntityID
+	Children   []ChildrenChange
+	Components []ComponentChange
+	Relations  []RelationChange
+	Resources  []ResourceChange
+}
+
+// Empty returns true if the delta contains no changes.
+func (delta *Delta) Empty() bool {
+	return len(delta.Created) == 0 && len(delta.Destroyed) == 0 && len(delta.Children) == 0 &&
+		len(delta.Components) == 0 && len(delta.Relations) == 0 && len(delta.Resources) == 0
+}
+
+// Diff returns the changes from the prev snapshot to the next snapshot of a scene. The
+// changes are applied to another scene with Scene.ApplyDelta, which is used to replicate
+// a scene by only sending what has changed. Values are compared with reflect.DeepEqual.
+func Diff(prev, next Snapshot) (*Delta, error) {
+	a, err := decodeSnapshot(prev)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decodeSnapshot(next)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &Delta{}
+	// destroyed contains the IDs of the entities alive in prev, but not in next.
+	destroyed := make(map[EntityID]bool)
+	for index := 0; index < len(a.entities) || index < len(b.entities); index++ {
+		i := uint32(index)
+		aliveA := index < len(a.entities) && a.entities[index].alive
+		aliveB := index < len(b.entities) && b.entities[index].alive
+		same := aliveA && aliveB && a.entities[index].generation == b.entities[index].generation
+		if aliveA && !same {
+			delta.Destroyed = append(delta.Destroyed, a.id(i))
+			destroyed[a.id(i)] = true
+		}
+		if aliveB && !same {
+			delta.Created = append(delta.Created, b.id(i))
+		}
+		if !aliveB {
+			continue
+		}
+
+		var childrenA []uint32
+		if same {
+			childrenA = a.entities[index].children
+		}
+		childrenB := b.entities[index].children
+		if !equalIndices(childrenA, childrenB) {
+			change := ChildrenChange{Entity: b.id(i), Children: make([]EntityID, len(childrenB))}
+			for j, child := range childrenB {
+				change.Children[j] = b.id(child)
+			}
+			delta.Children = append(delta.Children, change)
+		}
+	}
+
+	for _, name := range blockNames(a.components, b.components, func(block componentBlock) string { return block.codec.typeName() }) {
+		prevValues := make(map[EntityID]any)
+		for _, block := range a.components {
+			if block.codec.typeName() == name {
+				for i, index := range block.indices {
+					prevValues[a.id(index)] = block.codec.value(block.values, i)
+				}
+			}
+		}
+		for _, block := range b.components {
+			if block.codec.typeName() != name {
+				continue
+			}
+			for i, index := range block.indices {
+				id, value := b.id(index), block.codec.value(block.values, i)
+				prevValue, ok := prevValues[id]
+				delete(prevValues, id)
+				switch {
+				case !ok:
+					delta.Components = append(delta.Components, ComponentChange{ChangeAdded, id, name, value})
+				case !reflect.DeepEqual(prevValue, value):
+					delta.Components = append(delta.Components, ComponentChange{ChangeModified, id, name, value})
+				}
+			}
+		}
+		// The remaining values have been removed. They are added in the order of prev.
+		for _, block := range a.components {
+			if block.codec.typeName() != name {
+				continue
+			}
+			for _, index := range block.indices {
+				id := a.id(index)
+				if _, ok := prevValues[id]; ok && !destroyed[id] {
+					delta.Components = append(delta.Components, ComponentChange{ChangeRemoved, id, name, nil})
+				}
+			}
+		}
+	}
+
+	type pair struct{ source, target EntityID }
+	for _, name := range blockNames(a.relations, b.relations, func(block relationBlock) string { return block.codec.typeName() }) {
+		prevValues := make(map[pair]any)
+		for _, block := range a.relations {
+			if block.codec.typeName() == name {
+				for i := 0; i < len(block.indices); i += 2 {
+					prevValues[pair{a.id(block.indices[i]), a.id(block.indices[i+1])}] = block.codec.value(block.values, i/2)
+				}
+			}
+		}
+		for _, block := range b.relations {
+			if block.codec.typeName() != name {
+				continue
+			}
+			for i := 0; i < len(block.indices); i += 2 {
+				key, value := pair{b.id(block.indices[i]), b.id(block.indices[i+1])}, block.codec.value(block.values, i/2)
+				prevValue, ok := prevValues[key]
+				delete(prevValues, key)
+				switch {
+				case !ok:
+					delta.Relations = append(delta.Relations, RelationChange{ChangeAdded, key.source, key.target, name, value})
+				case !reflect.DeepEqual(prevValue, value):
+					delta.Relations = append(delta.Relations, RelationChange{ChangeModified, key.source, key.target, name, value})
+				}
+			}
+		}
+		for _, block := range a.relations {
+			if block.codec.typeName() != name {
+				continue
+			}
+			for i := 0; i < len(block.indices); i += 2 {
+				key := pair{a.id(block.indices[i]), a.id(block.indices[i+1])}
+				if _, ok := prevValues[key]; ok && !destroyed[key.source] && !destroyed[key.target] {
+					delta.Relations = append(delta.Relations, RelationChange{ChangeRemoved, key.source, key.target, name, nil})
+				}
+			}
+		}
+	}
+
+	prevResources := make(map[string]any)
+	for _, block := range a.resources {
+		prevResources[block.codec.typeName()] = block.codec.value(block.values, 0)
+	}
+	for _, block := range b.resources {
+		name, value := block.codec.typeName(), block.codec.value(block.values, 0)
+		prevValue, ok := prevResources[name]
+		delete(prevResources, name)
+		switch {
+		case !ok:
+			delta.Resources = append(delta.Resources, ResourceChange{ChangeAdded, name, value})
+		case !reflect.DeepEqual(prevValue, value):
+			delta.Resources = append(delta.Resources, ResourceChange{ChangeModified, name, value})
+		}
+	}
+	for _, block := range a.resources {
+		if _, ok := prevResources[block.codec.typeName()]; ok {
+			delta.Resources = append(delta.Resources, ResourceChange{ChangeRemoved, block.codec.typeName(), nil})
+		}
+	}
+	return delta, nil
+}
+
+// blockNames returns the type names of the blocks in a and b, in the order they appear.
+func blockNames[B any](a, b []B, name func(block B) string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, blocks := range [][]B{a, b} {
+		for _, block := range blocks {
+			if n := name(block); !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+func equalIndices(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyDelta applies the changes in the delta to the scene, which must contain the
+// entities of the snapshot the delta was created from, with the same IDs. Created entities
+// get the IDs they have in the scene the delta was created from, so entities should not
+// be created with NewEntity in a scene which deltas are applied to. An error is returned,
+// and the scene is not changed, if the delta does not fit the scene or contains
+// unregistered type names.
+func (scene *Scene) ApplyDelta(delta *Delta) error {
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+
+	// The delta is validated before the scene is changed.
+	destroyed := make(map[EntityID]bool, len(delta.Destroyed))
+	for _, id := range delta.Destroyed {
+		if !scene.Alive(id) {
+			return fmt.Errorf("ecs: destroyed entity %d not in the scene", id)
+		}
+		destroyed[id] = true
+	}
+	created := make(map[EntityID]bool, len(delta.Created))
+	for _, id := range delta.Created {
+		index := id.Index()
+		if index < uint32(len(scene.entities)) && scene.entities[index].alive && !destroyed[scene.entityAt(index).id] {
+			return fmt.Errorf("ecs: created entity %d already in the scene", id)
+		}
+		created[id] = true
+	}
+	exists := func(id EntityID) error {
+		if (scene.Alive(id) && !destroyed[id]) || created[id] {
+			return nil
+		}
+		return fmt.Errorf("ecs: entity %d not in the scene", id)
+	}
+	for _, change := range delta.Children {
+		if err := exists(change.Entity); err != nil {
+			return err
+		}
+		for _, child := range change.Children {
+			if err := exists(child); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scene.deltaHierarchyError(delta, destroyed); err != nil {
+		return err
+	}
+	components := make([]typeCodec, len(delta.Components))
+	for i, change := range delta.Components {
+		if err := exists(change.Entity); err != nil {
+			return err
+		}
+		codec, err := lookupType(change.Type)
+		if err != nil {
+			return err
+		}
+		if err := checkChangeValue(codec, change.Change, change.Value); err != nil {
+			return fmt.Errorf("ecs: component %q of entity %d: %w", change.Type, change.Entity, err)
+		}
+		components[i] = codec
+	}
+	relations := make([]relationCodec, len(delta.Relations))
+	for i, change := range delta.Relations {
+		if err := exists(change.Source); err != nil {
+			return err
+		}
+		if err := exists(change.Target); err != nil {
+			return err
+		}
+		codec, err := lookupRelation(change.Type)
+		if err != nil {
+			return err
+		}
+		if err := checkChangeValue(codec, change.Change, change.Value); err != nil {
+			return fmt.Errorf("ecs: relation %q from entity %d: %w", change.Type, change.Source, err)
+		}
+		relations[i] = codec
+	}
+	resources := make([]typeCodec, len(delta.Resources))
+	for i, change := range delta.Resources {
+		codec, err := lookupType(change.Type)
+		if err != nil {
+			return err
+		}
+		if err := checkChangeValue(codec, change.Change, change.Value); err != nil {
+			return fmt.Errorf("ecs: resource %q: %w", change.Type, err)
+		}
+		resources[i] = codec
+	}
+
+	// Children which are not destroyed have got a new parent, or no parent, so they are
+	// detached before any entity is removed with its descendants.
+	for _, id := range delta.Destroyed {
+		scene.detachChildren(id.Index(), destroyed)
+	}
+	for _, id := range delta.Destroyed {
+		if !scene.Alive(id) {
+			continue // removed with its parent
+		}
+		entity := Entity{id, scene}
+		scene.removeEntity(&entity)
+	}
+	for _, id := range delta.Created {
+		scene.createEntity(id)
+	}
+	// All changed children are detached before any are attached, so that the hierarchy
+	// never contains a cycle while the changes are applied.
+	for _, change := range delta.Children {
+		scene.detachChildren(change.Entity.Index(), nil)
+	}
+	for _, change := range delta.Children {
+		parent := Entity{change.Entity, scene}
+		for _, id := range change.Children {
+			child := Entity{id, scene}
+			child.SetParent(&parent)
+		}
+	}
+	for i, change := range delta.Components {
+		entity := Entity{change.Entity, scene}
+		if change.Change == ChangeRemoved {
+			components[i].removeComponent(&entity)
+		} else {
+			components[i].addComponent(&entity, change.Value)
+		}
+	}
+	for i, change := range delta.Relations {
+		source, target := Entity{change.Source, scene}, Entity{change.Target, scene}
+		if change.Change == ChangeRemoved {
+			relations[i].removeRelation(&source, &target)
+		} else {
+			relations[i].addRelation(&source, &target, change.Value)
+		}
+	}
+	for i, change := range delta.Resources {
+		if change.Change == ChangeRemoved {
+			resources[i].removeResource(scene)
+		} else {
+			resources[i].setResource(scene, change.Value)
+		}
+	}
+	return nil
+}
+
+// deltaHierarchyError returns an error if applying the children changes of the delta,
+// whose entities must exist, would make an entity the child of two entities or its own
+// ancestor. The parents of entities which are not in the changes are kept.
+func (scene *Scene) deltaHierarchyError(delta *Delta, destroyed map[EntityID]bool) error {
+	n := len(scene.entities)
+	for _, id := range delta.Created {
+		if index := int(id.Index()); index >= n {
+			n = index + 1
+		}
+	}
+	parents := newParents(n)
+	for index, record := range scene.entities {
+		id := newEntityID(uint32(index), record.generation)
+		if record.alive && !destroyed[id] && record.parent != 0 && !destroyed[record.parent] {
+			parents[index] = int(record.parent.Index())
+		}
+	}
+	// The changed children are detached before any are attached, like when the delta is
+	// applied.
+	for _, change := range delta.Children {
+		if !scene.Alive(change.Entity) || destroyed[change.Entity] {
+			continue // created by the delta, without children
+		}
+		for _, child := range scene.entities[change.Entity.Index()].children {
+			parents[child.Index()] = -1
+		}
+	}
+	attached := make(map[EntityID]bool)
+	for _, change := range delta.Children {
+		for _, child := range change.Children {
+			if attached[child] {
+				return fmt.Errorf("ecs: entity %d is the child of two entities", child)
+			}
+			attached[child] = true
+			parents[child.Index()] = int(change.Entity.Index())
+		}
+	}
+	if !acyclic(parents) {
+		return errors.New("ecs: entity is its own ancestor")
+	}
+	return nil
+}
+
+// checkChangeValue returns an error if the value of an added or modified component,
+// relation or resource is not of the registered type.
+func checkChangeValue(codec valueCodec, change Change, value any) error {
+	if change == ChangeRemoved {
+		return nil
+	}
+	return codec.checkValue(value)
+}
+
+// detachChildren detaches the children of the entity with the index which are not
+// destroyed.
+func (scene *Scene) detachChildren(index uint32, destroyed map[EntityID]bool) {
+	children := scene.entities[index].children
+	for i := len(children) - 1; i >= 0; i-- {
+		if !destroyed[children[i]] {
+			scene.detach(children[i].Index())
+		}
+	}
+}
+
+// createEntity creates an entity with the ID, which must not be alive.
+func (scene *Scene) createEntity(id EntityID) {
+	index := id.Index()
+	for uint32(len(scene.entities)) <= index {
+		scene.freeEntities = append(scene.freeEntities, uint32(len(scene.entities)))
+		scene.entities = append(scene.entities, entityRecord{})
+	}
+	for i, free := range scene.freeEntities {
+		if free == index {
+			scene.freeEntities = append(scene.freeEntities[:i], scene.freeEntities[i+1:]...)
+			break
+		}
+	}
+	record := &scene.entities[index]
+	record.generation = id.Generation()
+	record.alive = true
+}
+
+const (
+	deltaMagic   = "ECSD"
+	deltaVersion = 1
+)
+
+// MarshalBinary encodes the delta in the same format as snapshots, so that it can be
+// sent to the scenes it is applied to.
+func (delta *Delta) MarshalBinary() ([]byte, error) {
+	w := &snapshotWriter{}
+	w.buffer = append(w.buffer, deltaMagic...)
+	w.uvarint(deltaVersion)
+
+	w.ids(delta.Created)
+	w.ids(delta.Destroyed)
+	w.uvarint(uint64(len(delta.Children)))
+	for _, change := range delta.Children {
+		w.uvarint(uint64(change.Entity))
+		w.ids(change.Children)
+	}
+
+	w.uvarint(uint64(len(delta.Components)))
+	for _, change := range delta.Components {
+		w.uvarint(uint64(change.Change))
+		w.uvarint(uint64(change.Entity))
+		if err := w.value(change.Type, change.Change, change.Value, lookupTypeValues); err != nil {
+			return nil, err
+		}
+	}
+	w.uvarint(uint64(len(delta.Relations)))
+	for _, change := range delta.Relations {
+		w.uvarint(uint64(change.Change))
+		w.uvarint(uint64(change.Source))
+		w.uvarint(uint64(change.Target))
+		if err := w.value(change.Type, change.Change, change.Value, lookupRelationValues); err != nil {
+			return nil, err
+		}
+	}
+	w.uvarint(uint64(len(delta.Resources)))
+	for _, change := range delta.Resources {
+		w.uvarint(uint64(change.Change))
+		if err := w.value(change.Type, change.Change, change.Value, lookupTypeValues); err != nil {
+			return nil, err
+		}
+	}
+	return w.buffer, nil
+}
+
+// UnmarshalBinary decodes a delta encoded by MarshalBinary. An error is returned if the
+// data is corrupt or contains unregistered type names.
+func (delta *Delta) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(deltaMagic)) {
+		return errors.New("ecs: data is not a delta")
+	}
+	r := &snapshotReader{data: data[len(deltaMagic):]}
+	if version := r.uvarint(); r.err == nil && version != deltaVersion {
+		return fmt.Errorf("ecs: unsupported delta version %d", version)
+	}
+
+	decoded := Delta{Created: r.ids(), Destroyed: r.ids()}
+	decoded.Children = make([]ChildrenChange, r.count())
+	for i := range decoded.Children {
+		decoded.Children[i] = ChildrenChange{EntityID(r.uvarint()), r.ids()}
+	}
+
+	decoded.Components = make([]ComponentChange, r.count())
+	for i := range decoded.Components {
+		change := &decoded.Components[i]
+		change.Change, change.Entity = Change(r.uvarint()), EntityID(r.uvarint())
+		var err error
+		if change.Type, change.Value, err = r.value(change.Change, lookupTypeValues); err != nil {
+			return err
+		}
+	}
+	decoded.Relations = make([]RelationChange, r.count())
+	for i := range decoded.Relations {
+		change := &decoded.Relations[i]
+		change.Change, change.Source, change.Target = Change(r.uvarint()), EntityID(r.uvarint()), EntityID(r.uvarint())
+		var err error
+		if change.Type, change.Value, err = r.value(change.Change, lookupRelationValues); err != nil {
+			return err
+		}
+	}
+	decoded.Resources = make([]ResourceChange, r.count())
+	for i := range decoded.Resources {
+		change := &decoded.Resources[i]
+		change.Change = Change(r.uvarint())
+		var err error
+		if change.Type, change.Value, err = r.value(change.Change, lookupTypeValues); err != nil {
+			return err
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*delta = decoded
+	return nil
+}
+
+func (w *snapshotWriter) ids(ids []EntityID) {
+	w.uvarint(uint64(len(ids)))
+	for _, id := range ids {
+		w.uvarint(uint64(id))
+	}
+}
+
+// valueLookup finds the codec of a registered type name.
+type valueLookup func(name string) (valueCodec, error)
+
+func lookupTypeValues(name string) (valueCodec, error) {
+	codec, err := lookupType(name)
+	if err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+func lookupRelationValues(name string) (valueCodec, error) {
+	codec, err := lookupRelation(name)
+	if err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+// value writes the type name of a change, and the value if it is not a removal.
+func (w *snapshotWriter) value(name string, change Change, value any, lookup valueLookup) error {
+	w.string(name)
+	if change == ChangeRemoved {
+		return nil
+	}
+	codec, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := codec.writeValues(w, codec.valueSlice([]any{value})); err != nil {
+		return fmt.Errorf("ecs: encoding %q: %w", name, err)
+	}
+	return nil
+}
+
+func (r *snapshotReader) ids() []EntityID {
+	ids := make([]EntityID, r.count())
+	for i := range ids {
+		ids[i] = EntityID(r.uvarint())
+	}
+	return ids
+}
+
+// value reads the type name and value written by snapshotWriter.value.
+func (r *snapshotReader) value(change Change, lookup valueLookup) (string, any, error) {
+	name := r.string()
+	if r.err != nil || change == ChangeRemoved {
+		return name, nil, r.err
+	}
+	codec, err := lookup(name)
+	if err != nil {
+		return "", nil, err
+	}
+	values, err := codec.readValues(r, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, codec.value(values, 0), nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+// sendDelta diffs the snapshots, sends the delta through MarshalBinary and applies it to
+// the client.
+func sendDelta(t *testing.T, prev, next ecs.Snapshot, client *ecs.Scene) *ecs.Delta {
+	delta, err := ecs.Diff(prev, next)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	data, err := delta.MarshalBinary()
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+
+	received := &ecs.Delta{}
+	err = received.UnmarshalBinary(data)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	err = client.ApplyDelta(received)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	return delta
+}
+
+func TestDelta(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		server := newSaveScene(storage)
+		prev, _ := server.Snapshot()
+		client := ecs.NewScene(storage)
+		client.Restore(prev)
+
+		parent, a, b := findByName(server, "parent"), findByName(server, "a"), findByName(server, "b")
+		c := server.NewEntity()
+		ecs.AddComponent(&c, &nameTag{Name: "c"})
+		a.SetParent(nil)
+		b.SetParent(&c)
+		parent.Remove()
+		ecs.AddComponent(&a, &transform{X: 5})
+		ecs.RemoveRelation[follows](&b, &a)
+		ecs.AddRelation(&a, &b, &follows{Distance: 1})
+		ecs.SetResource(server, &settings{Volume: 3})
+		next, _ := server.Snapshot()
+
+		delta := sendDelta(t, prev, next, client)
+		t.Run("Expected correct result", subx.Test(subx.Value(delta.Destroyed), subx.DeepEqual([]ecs.EntityID{parent.ID()})))
+		t.Run("Expected correct result", subx.Test(subx.Value(delta.Created), subx.DeepEqual([]ecs.EntityID{c.ID()})))
+
+		// The client has the same state as the server.
+		clientSnapshot, _ := client.Snapshot()
+		remaining, err := ecs.Diff(next, clientSnapshot)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(remaining.Empty()), subx.CompareEqual(true)))
+
+		clientA, clientB, clientC := findByName(client, "a"), findByName(client, "b"), findByName(client, "c")
+		t.Run("Expected correct result", subx.Test(subx.Value(clientC.ID()), subx.CompareEqual(c.ID())))
+		t.Run("Expected correct result", subx.Test(subx.Value(clientC.Children()), subx.DeepEqual([]ecs.Entity{clientB})))
+		tf, _ := ecs.GetComponent[transform](&clientA)
+		t.Run("Expected correct result", subx.Test(subx.Value(*tf), subx.CompareEqual(transform{X: 5})))
+		rel, _ := ecs.GetRelation[follows](&clientA, &clientB)
+		t.Run("Expected correct result", subx.Test(subx.Value(rel.Distance), subx.CompareEqual(1.0)))
+		_, err = ecs.GetRelation[follows](&clientB, &clientA)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		res, _ := ecs.Resource[settings](client)
+		t.Run("Expected correct result", subx.Test(subx.Value(res.Volume), subx.CompareEqual(3)))
+
+		// Diffing a snapshot with itself gives an empty delta.
+		delta, _ = ecs.Diff(next, next)
+		t.Run("Expected correct result", subx.Test(subx.Value(delta.Empty()), subx.CompareEqual(true)))
+	}
+}
+
+func TestDeltaDestroyedParent(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		server := ecs.NewScene(storage)
+		a, b, c := server.NewEntity(), server.NewEntity(), server.NewEntity()
+		ecs.AddComponent(&c, &nameTag{Name: "c"})
+		b.SetParent(&a)
+		c.SetParent(&b)
+		prev, _ := server.Snapshot()
+		client := ecs.NewScene(storage)
+		client.Restore(prev)
+
+		// The grandchild survives when its parent and grandparent are destroyed.
+		c.SetParent(nil)
+		a.Remove()
+		next, _ := server.Snapshot()
+		sendDelta(t, prev, next, client)
+
+		clientC := findByName(client, "c")
+		_, hasParent := clientC.Parent()
+		t.Run("Expected correct result", subx.Test(subx.Value(client.Alive(c.ID())), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(hasParent), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(client.Alive(b.ID())), subx.CompareEqual(false)))
+	}
+}
+
+func TestDeltaErrors(t *testing.T) {
+	scene := newSaveScene(ecs.PoolStorage)
+	parent, a, b := findByName(scene, "parent"), findByName(scene, "a"), findByName(scene, "b")
+	removed := scene.NewEntity()
+	removed.Remove()
+	children := parent.Children()
+
+	for _, delta := range []*ecs.Delta{
+		{Destroyed: []ecs.EntityID{removed.ID()}},
+		{Created: []ecs.EntityID{a.ID()}},
+		{Components: []ecs.ComponentChange{{Change: ecs.ChangeAdded, Entity: removed.ID(), Type: "transform", Value: transform{}}}},
+		{Components: []ecs.ComponentChange{{Change: ecs.ChangeAdded, Entity: a.ID(), Type: "unknown"}}},
+		{Destroyed: []ecs.EntityID{a.ID()}, Resources: []ecs.ResourceChange{{Change: ecs.ChangeRemoved, Type: "unknown"}}},
+		// Values which are missing or of another type than the registered type.
+		{Components: []ecs.ComponentChange{{Change: ecs.ChangeAdded, Entity: a.ID(), Type: "transform"}}},
+		{Components: []ecs.ComponentChange{{Change: ecs.ChangeModified, Entity: a.ID(), Type: "transform", Value: &transform{}}}},
+		{Relations: []ecs.RelationChange{{Change: ecs.ChangeAdded, Source: a.ID(), Target: a.ID(), Type: "follows", Value: transform{}}}},
+		{Resources: []ecs.ResourceChange{{Change: ecs.ChangeModified, Type: "settings", Value: "settings"}}},
+		// Children which would be their own ancestor, or the child of two entities. The
+		// parent of a is kept, since the children of parent are not changed.
+		{Children: []ecs.ChildrenChange{{Entity: a.ID(), Children: []ecs.EntityID{parent.ID()}}}},
+		{Children: []ecs.ChildrenChange{{Entity: a.ID(), Children: []ecs.EntityID{a.ID()}}}},
+		{Children: []ecs.ChildrenChange{{Entity: a.ID(), Children: []ecs.EntityID{b.ID()}}, {Entity: b.ID(), Children: []ecs.EntityID{a.ID()}}}},
+		{Children: []ecs.ChildrenChange{{Entity: a.ID(), Children: []ecs.EntityID{b.ID()}}, {Entity: parent.ID(), Children: []ecs.EntityID{b.ID()}}}},
+	} {
+		err := scene.ApplyDelta(delta)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+	// Nothing is changed when applying fails.
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(a.ID())), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(parent.Children()), subx.DeepEqual(children)))
+
+	for _, data := range [][]byte{nil, []byte("ECSD\x02"), []byte("ECSD\x01\x05")} {
+		err := (&ecs.Delta{}).UnmarshalBinary(data)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+}
@@ -19,7 +19,16 @@
 // To create a new entity, first make a new scene. Then, create a entity from the scene.
 //	scene := ecs.Scene{}
 //	entity := scene.NewEntity()
-// Now you can create as many entities as you want.
+// Now you can create as many entities as you want. Each entity has an ID, which can be
+// checked against the scene after the entity has been removed.
+//  id := entity.ID()
+//  entity.Remove()
+//  scene.Alive(id) // false
+//
+// Scenes store components in pools by default. A scene can instead store entities with
+// the same component types together in tables, which is faster for queries over several
+// components.
+//	scene := ecs.NewScene(ecs.ArchetypeStorage)
 //
 // Adding Components
 //
@@ -33,8 +42,81 @@
 // The component can also be retrieved and removed.
 //  component2, _ := ecs.GetComponent[info](&entiy) // Get single component from entity
 //  ecs.RemoveComponent[info](&entity)              // Remove component from entity
+// Errors can be inspected with errors.Is and errors.As, and TryGet checks for a
+// component without creating an error.
+//  errors.Is(err, ecs.ErrComponentMissing)
+//  component3, ok := ecs.TryGet[info](&entity)
 // It is also possible to get all components of a type, which is very useful in systems.
 //  components := ecs.AllComponents[info](scene)    // Get all components of same type
+// Checking for components with Has and HasAll only tests bits in the entity signature.
+//  ecs.HasAll(&entity, ecs.TypeOf[info](), ecs.TypeOf[position]())
+// Components of zero-size marker types are tags, which are only stored in the entity
+// signature, so they are cheap to add and remove often. AddTag checks that the type is
+// zero-size.
+//  ecs.AddTag[dead](&entity)
+//  ecs.Query1(scene, update, ecs.Without[dead]())
+//
+// Entities can be organized in a hierarchy. Removing an entity removes its descendants.
+//  weapon.SetParent(&character)
+//  children := character.Children()
+//  character.WalkDepthFirst(func(entity *ecs.Entity) bool { return true })
+// Other relations between entities are added as relations, which are removed when the
+// target entity is removed.
+//  ecs.AddRelation(&sword, &chest, &inInventory{})
+//  ecs.Query1(scene, update, ecs.RelatedTo[inInventory](&chest))
+//
+// Entities with the same components are created from a prefab. Components containing
+// slices or maps implement Copier to be copied deeply.
+//  enemy := ecs.NewPrefab()
+//  ecs.SetPrefabComponent(enemy, &health{hp: 10})
+//  entity, err := scene.Instantiate(enemy, ecs.Override(&health{hp: 20}))
+// Entities are also copied with Clone, and moved to another scene with MoveEntity.
+//  clone, err := scene.Clone(&entity)
+//  moved, err := ecs.MoveEntity(scene, otherScene, &entity)
+//
+// Values which are not per entity, like the camera or the input state, are stored as
+// resources on the scene.
+//  ecs.SetResource(scene, &camera{zoom: 1})
+//  cam, ok := ecs.Resource[camera](scene)
+//
+// Saving Scenes
+//
+// Scenes are saved as JSON. Only components, relations and resources of types registered
+// with a name are saved.
+//  ecs.RegisterComponent[position]("position")
+//  data, err := json.Marshal(scene)
+//  err = json.Unmarshal(data, loadedScene)
+// Snapshots are a faster and smaller binary format, which keeps the entity IDs. Restoring
+// a snapshot replaces the entities of the scene, which is useful for rollback.
+//  snapshot, err := scene.Snapshot()
+//  err = scene.Restore(snapshot)
+// A scene is replicated by sending only the changes between two snapshots.
+//  delta, err := ecs.Diff(prev, next)
+//  data, err := delta.MarshalBinary()
+//  err = client.ApplyDelta(delta)
+//
+// Querying Components
+//
+// Entities with several components are visited with Query1 to Query6.
+//  ecs.Query2(scene, func(entity *ecs.Entity, p *position, v *velocity) {
+//      p.x += v.x
+//  })
+// Filters narrow down the visited entities further.
+//  ecs.Query2(scene, update, ecs.Without[frozen](), ecs.With[player]())
+// Large numbers of components can be processed on several goroutines.
+//  ecs.ParallelEach(scene, func(entity *ecs.Entity, p *particle) {
+//      p.x += p.vx
+//  })
+//
+// Adding or removing components and entities while iterating over components is not
+// safe. Record the changes in a command buffer instead, and flush it afterwards.
+//  ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+//      if h.hp <= 0 {
+//          scene.Commands().RemoveEntity(entity)
+//      }
+//  })
+//  scene.Flush()
+// Systems have their own command buffer, which is flushed after the system is updated.
 //
 // Adding Systems
 //
@@ -52,10 +134,32 @@
 //  func (sys *system) Init() {} // Optional
 //
 //  func (sys *system) Delete() {} // Optional
+// Systems can visit only the components added or changed since their last update. Use
+// Mut or MarkChanged to mark a component as changed.
+//  ecs.Query1(sys.Scene(), send, ecs.Changed[position](sys))
+//  removed := ecs.RemovedComponents[position](sys)
 // Then, add the system to the scene.
 //  scene.AddSystem(system{})
+// Systems are updated in the order they are added, unless they are ordered with labels.
+//  scene.AddSystem(physics, ecs.Label("physics"))
+//  scene.AddSystem(render, ecs.After("physics"))
+// Systems declaring the components they access are updated in parallel, when they do not
+// write components accessed by each other.
+//  scene.AddSystem(movement, ecs.Writes[position](), ecs.Reads[velocity]())
+// Systems run in the Update stage by default. Systems in the FixedUpdate stage are
+// updated with a fixed timestep, and Alpha tells how far the scene is into the next step.
+//  scene.SetFixedTimestep(1.0/60, 5)
+//  scene.AddSystem(physics, ecs.InStage(ecs.StageFixedUpdate))
+//  scene.AddSystem(render, ecs.InStage(ecs.StageRender))
+// Systems can be removed, or disabled alone or in groups.
+//  scene.AddSystem(enemyAI, ecs.InGroup("gameplay"))
+//  scene.SetGroupEnabled("gameplay", false) // Pause the game
+//  scene.RemoveSystem(enemyAI)
+// Run conditions decide when a system is updated, and are combined with And, Or and Not.
+//  scene.AddSystem(spawner, ecs.RunIf(ecs.Not(paused), ecs.EveryN(10)))
 // Scene.Update, Scene.Init and Scene.Delete, calls Update, Init and
-// Delete on all systems added to the scene
+// Delete on all systems added to the scene. Systems added after Init are initialized
+// when they are added, and systems removed before Delete are deleted when they are removed.
 //  scene.Init()       // Calls system.Init
 //  scene.Update(0.01) // Calls system.Update
 //  scene.Delete()     // Calls system.Delete
@@ -14,21 +14,51 @@
 
 package ecs
 
-import (
-	"errors"
-)
+// EntityID identifies an entity in a scene. It combines the index of the entity with a
+// generation, which is increased every time the index is reused by a new entity. The
+// ID of a removed entity is therefore never valid again.
+type EntityID uint64
+
+func newEntityID(index, generation uint32) EntityID {
+	return EntityID(generation)<<32 | EntityID(index)
+}
+
+// Index returns the index of the entity in the scene.
+func (id EntityID) Index() uint32 {
+	return uint32(id)
+}
+
+// Generation returns the generation of the entity.
+func (id EntityID) Generation() uint32 {
+	return uint32(id >> 32)
+}
 
 // Entity is an enitity created by a scene. An entity should only be created from Scene.NewEntity.
+// Copies of an entity refer to the same entity, and all copies become invalid when the
+// entity is removed.
 type Entity struct {
-	id    uint32
+	id    EntityID
 	scene *Scene
 }
 
-// Remove removes the entity and all its components from the scene.
+// ID returns the ID of the entity.
+func (entity *Entity) ID() EntityID {
+	return entity.id
+}
+
+// Remove removes the entity, all its components and all its descendants from the scene.
+// ErrEntityDead is returned if the entity has already been removed.
 func (entity *Entity) Remove() error {
-	if entity.scene == nil || entity.id == 0 {
-		return errors.New("ecs: entity not registered to a scene (or has been deleted)")
+	if !entity.alive() {
+		return ErrEntityDead
+	}
+	if err := entity.scene.structuralError(); err != nil {
+		return err
 	}
 	entity.scene.removeEntity(entity)
 	return nil
 }
+
+func (entity *Entity) alive() bool {
+	return entity.scene != nil && entity.scene.Alive(entity.id)
+}
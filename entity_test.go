@@ -15,6 +15,8 @@
 package ecs_test
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/oyberntzen/ecs"
@@ -64,6 +66,58 @@ func TestEntityRemove(t *testing.T) {
 	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
 }
 
+func TestEntityRemoveCopy(t *testing.T) {
+	scene := ecs.Scene{}
+	entity := scene.NewEntity()
+	copied := entity
+
+	type comp struct {
+		num int
+	}
+
+	ecs.AddComponent(&entity, &comp{num: 10})
+
+	err := copied.Remove()
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(entity.ID())), subx.CompareEqual(false)))
+
+	_, err = ecs.GetComponent[comp](&entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	err = ecs.AddComponent(&entity, &comp{num: 11})
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	err = entity.Remove()
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+	components := ecs.AllComponents[comp](&scene)
+	t.Run("Expected correct result", subx.Test(subx.Value(len(components)), subx.CompareEqual(0)))
+}
+
+func TestEntityIDReuse(t *testing.T) {
+	scene := ecs.Scene{}
+	entity1 := scene.NewEntity()
+	entity1.Remove()
+	entity2 := scene.NewEntity()
+
+	type comp struct {
+		num int
+	}
+
+	t.Run("Expected correct result", subx.Test(subx.Value(entity2.ID().Index()), subx.CompareEqual(entity1.ID().Index())))
+	t.Run("Expected correct result", subx.Test(subx.Value(entity2.ID()), subx.CompareNotEqual(entity1.ID())))
+
+	ecs.AddComponent(&entity2, &comp{num: 1})
+	_, err := ecs.GetComponent[comp](&entity1)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	err = ecs.RemoveComponent[comp](&entity1)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+	result, ok := scene.Entity(entity2.ID())
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(result), subx.CompareEqual(entity2)))
+	_, ok = scene.Entity(entity1.ID())
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+}
+
 func TestEntityAddGetRemoveComponent(t *testing.T) {
 	scene := ecs.Scene{}
 	entity := scene.NewEntity()
@@ -107,6 +161,67 @@ func TestEntityAddGetRemoveComponent(t *testing.T) {
 	t.Run("Expected correct result", subx.Test(subx.Value(err2), subx.CompareNotEqual[error](nil)))
 }
 
+func TestEntityErrors(t *testing.T) {
+	scene := ecs.Scene{}
+	entity := scene.NewEntity()
+
+	type comp struct {
+		num int
+	}
+
+	_, err := ecs.GetComponent[comp](&entity)
+	var componentErr *ecs.ComponentError
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrComponentMissing)), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.As(err, &componentErr)), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(componentErr.Entity), subx.CompareEqual(entity.ID())))
+	t.Run("Expected correct result", subx.Test(subx.Value(componentErr.Type), subx.CompareEqual(reflect.TypeOf(comp{}))))
+	err = ecs.RemoveComponent[comp](&entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrComponentMissing)), subx.CompareEqual(true)))
+
+	entity.Remove()
+	err = ecs.AddComponent(&entity, &comp{})
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrEntityDead)), subx.CompareEqual(true)))
+	_, err = ecs.GetComponent[comp](&entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrEntityDead)), subx.CompareEqual(true)))
+	err = ecs.RemoveComponent[comp](&entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrEntityDead)), subx.CompareEqual(true)))
+	err = entity.Remove()
+	t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrEntityDead)), subx.CompareEqual(true)))
+
+	// The next entity reuses the index with the second generation.
+	next := scene.NewEntity()
+	_, err = ecs.GetComponent[comp](&next)
+	t.Run("Expected correct result", subx.Test(subx.Value(err.Error()), subx.CompareEqual("ecs: no component of type ecs_test.comp added to entity 0v2")))
+}
+
+func TestTryGet(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		entity, other := scene.NewEntity(), scene.NewEntity()
+
+		type comp struct {
+			num int
+		}
+
+		ecs.AddComponent(&entity, &comp{num: 3})
+		result, ok := ecs.TryGet[comp](&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(result.num), subx.CompareEqual(3)))
+		_, ok = ecs.TryGet[comp](&other)
+		t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+
+		// Misses do not allocate.
+		allocs := testing.AllocsPerRun(100, func() {
+			ecs.TryGet[comp](&other)
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(allocs), subx.CompareEqual(0.0)))
+
+		entity.Remove()
+		_, ok = ecs.TryGet[comp](&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+	}
+}
+
 func TestEntityAddGetRemoveComponentMany(t *testing.T) {
 	scene := ecs.Scene{}
 	entities := make([]ecs.Entity, 5)
@@ -138,6 +253,35 @@ func TestEntityAddGetRemoveComponentMany(t *testing.T) {
 	}
 }
 
+func TestEntityAddGetRemoveComponentPages(t *testing.T) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 3000)
+
+	type comp struct {
+		num int
+	}
+
+	for n := range entities {
+		entities[n] = scene.NewEntity()
+		if n%3 != 0 {
+			ecs.AddComponent(&entities[n], &comp{num: n})
+		}
+	}
+	for n := 0; n < len(entities); n += 2 {
+		ecs.RemoveComponent[comp](&entities[n])
+	}
+
+	for n := range entities {
+		result, err := ecs.GetComponent[comp](&entities[n])
+		if n%3 == 0 || n%2 == 0 {
+			t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		} else {
+			t.Run("Expected correct result", subx.Test(subx.Value(result.num), subx.CompareEqual(n)))
+		}
+	}
+	t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.AllComponents[comp](&scene))), subx.CompareEqual(1000)))
+}
+
 func TestAllComponents(t *testing.T) {
 	scene := ecs.Scene{}
 	entities := make([]ecs.Entity, 5)
@@ -173,9 +317,6 @@ func BenchmarkNewEntity(b *testing.B) {
 func BenchmarkEntityRemove(b *testing.B) {
 	scene := ecs.Scene{}
 	entities := make([]ecs.Entity, 100)
-	for n := 0; n < 100; n++ {
-		entities[n] = scene.NewEntity()
-	}
 
 	type comp1 struct {
 		num int
@@ -187,6 +328,7 @@ func BenchmarkEntityRemove(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		for n := 0; n < 100; n++ {
+			entities[n] = scene.NewEntity()
 			ecs.AddComponent(&entities[n], &comp1{num: 1})
 			ecs.AddComponent(&entities[n], &comp2{num: 2})
 		}
@@ -0,0 +1,52 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	// ErrEntityDead is returned when an entity is used after it has been removed, or is
+	// not registered to a scene.
+	ErrEntityDead = errors.New("ecs: entity not registered to a scene (or has been deleted)")
+	// ErrComponentMissing is matched by the ComponentError returned when an entity has no
+	// component of a type.
+	ErrComponentMissing = errors.New("ecs: component missing")
+)
+
+// ComponentError is returned when an entity has no component of a type. It matches
+// ErrComponentMissing with errors.Is.
+type ComponentError struct {
+	Entity EntityID
+	Type   reflect.Type
+}
+
+func newComponentError[T any](entity *Entity) *ComponentError {
+	return &ComponentError{entity.id, reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// Error returns the message of the error. The entity is written as its index and
+// generation, like 0v2.
+func (err *ComponentError) Error() string {
+	return fmt.Sprintf("ecs: no component of type %s added to entity %dv%d", err.Type, err.Entity.Index(), err.Entity.Generation())
+}
+
+// Is returns true if target is ErrComponentMissing.
+func (err *ComponentError) Is(target error) bool {
+	return target == ErrComponentMissing
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+// Filter restricts the entities visited by a query.
+type Filter interface {
+	// matcher returns a function reporting if an entity in the scene passes the filter.
+	matcher(scene *Scene) func(entity *Entity) bool
+}
+
+type withFilter[T any] struct{}
+
+// With returns a filter only passing entities with a component of type T.
+func With[T any]() Filter {
+	return withFilter[T]{}
+}
+
+func (withFilter[T]) matcher(scene *Scene) func(entity *Entity) bool {
+	id, ok := findComponentID[T](scene)
+	return func(entity *Entity) bool {
+		return ok && scene.entities[entity.id.Index()].signature.has(id)
+	}
+}
+
+type withoutFilter[T any] struct{}
+
+// Without returns a filter only passing entities without a component of type T.
+func Without[T any]() Filter {
+	return withoutFilter[T]{}
+}
+
+func (withoutFilter[T]) matcher(scene *Scene) func(entity *Entity) bool {
+	id, ok := findComponentID[T](scene)
+	return func(entity *Entity) bool {
+		return !ok || !scene.entities[entity.id.Index()].signature.has(id)
+	}
+}
+
+// OptionalFilter is a filter passing all entities, and giving access to the component
+// of type T of the entities visited by the query. The filter has no state, so the same
+// filter can be used by nested queries and by systems updated in parallel.
+type OptionalFilter[T any] struct{}
+
+// Optional returns a filter for accessing a component of type T which the entities
+// may or may not have.
+//
+//	tint := ecs.Optional[tint]()
+//	ecs.Query1(scene, func(entity *ecs.Entity, s *sprite) {
+//	    if t := tint.Get(entity); t != nil {
+//	        // Use tint
+//	    }
+//	}, tint)
+func Optional[T any]() *OptionalFilter[T] {
+	return &OptionalFilter[T]{}
+}
+
+// Get returns the component of type T of the entity visited by the query, or nil if the
+// entity has no component of type T.
+func (filter *OptionalFilter[T]) Get(entity *Entity) *T {
+	result, _ := TryGet[T](entity)
+	return result
+}
+
+func (filter *OptionalFilter[T]) matcher(scene *Scene) func(entity *Entity) bool {
+	return func(entity *Entity) bool {
+		return true
+	}
+}
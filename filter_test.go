@@ -0,0 +1,101 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type frozen struct {
+	since int
+}
+
+type tint struct {
+	color int
+}
+
+func TestFilterWithWithout(t *testing.T) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 6)
+	for n := 0; n < 6; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{})
+		if n%2 == 0 {
+			ecs.AddComponent(&entities[n], &health{hp: n})
+		}
+		if n%3 == 0 {
+			ecs.AddComponent(&entities[n], &frozen{})
+		}
+	}
+
+	withCount := 0
+	ecs.Query1(&scene, func(entity *ecs.Entity, p *position) {
+		withCount++
+	}, ecs.With[health]())
+	t.Run("Expected correct result", subx.Test(subx.Value(withCount), subx.CompareEqual(3)))
+
+	withoutCount := 0
+	ecs.Query1(&scene, func(entity *ecs.Entity, p *position) {
+		withoutCount++
+	}, ecs.Without[frozen]())
+	t.Run("Expected correct result", subx.Test(subx.Value(withoutCount), subx.CompareEqual(4)))
+
+	sum := 0
+	ecs.Query2(&scene, func(entity *ecs.Entity, p *position, h *health) {
+		sum += h.hp
+	}, ecs.Without[frozen]())
+	t.Run("Expected correct result", subx.Test(subx.Value(sum), subx.CompareEqual(6)))
+}
+
+func TestFilterOptional(t *testing.T) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 4)
+	for n := 0; n < 4; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{})
+		if n < 2 {
+			ecs.AddComponent(&entities[n], &tint{color: 5})
+		}
+	}
+
+	tintFilter := ecs.Optional[tint]()
+	visited := 0
+	sum := 0
+	ecs.Query1(&scene, func(entity *ecs.Entity, p *position) {
+		visited++
+		if tint := tintFilter.Get(entity); tint != nil {
+			sum += tint.color
+		}
+	}, tintFilter)
+	t.Run("Expected correct result", subx.Test(subx.Value(visited), subx.CompareEqual(4)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sum), subx.CompareEqual(10)))
+
+	// The filter can be shared by nested queries.
+	pairs := 0
+	ecs.Query1(&scene, func(outer *ecs.Entity, p *position) {
+		ecs.Query1(&scene, func(inner *ecs.Entity, p *position) {
+			if tintFilter.Get(inner) != nil {
+				pairs++
+			}
+		}, tintFilter)
+		if tintFilter.Get(outer) != nil {
+			pairs += 10
+		}
+	}, tintFilter)
+	t.Run("Expected correct result", subx.Test(subx.Value(pairs), subx.CompareEqual(28)))
+}
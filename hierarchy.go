@@ -0,0 +1,179 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "errors"
+
+// SetParent makes the entity a child of the parent, and removes it from its current
+// parent. The entity is removed from its parent if parent is nil. Children are removed
+// with their parent. An error is returned if the entities are deleted, belong to
+// different scenes, or if the parent is the entity or one of its descendants.
+func (entity *Entity) SetParent(parent *Entity) error {
+	if !entity.alive() {
+		return ErrEntityDead
+	}
+	scene := entity.scene
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+	if parent == nil {
+		scene.detach(entity.id.Index())
+		return nil
+	}
+	if !parent.alive() || parent.scene != scene {
+		return errors.New("ecs: parent not registered to the scene of the entity (or has been deleted)")
+	}
+	for ancestor := parent.id; ancestor != 0; ancestor = scene.entities[ancestor.Index()].parent {
+		if ancestor == entity.id {
+			return errors.New("ecs: parent is the entity or one of its descendants")
+		}
+	}
+
+	scene.detach(entity.id.Index())
+	scene.entities[entity.id.Index()].parent = parent.id
+	record := &scene.entities[parent.id.Index()]
+	record.children = append(record.children, entity.id)
+	return nil
+}
+
+// Parent returns the parent of the entity. False is returned if the entity has no
+// parent, or if it is deleted.
+func (entity *Entity) Parent() (Entity, bool) {
+	if !entity.alive() {
+		return Entity{}, false
+	}
+	parent := entity.scene.entities[entity.id.Index()].parent
+	if parent == 0 {
+		return Entity{}, false
+	}
+	return Entity{parent, entity.scene}, true
+}
+
+// Children returns the children of the entity, in the order they were added.
+func (entity *Entity) Children() []Entity {
+	if !entity.alive() {
+		return nil
+	}
+	ids := entity.scene.entities[entity.id.Index()].children
+	children := make([]Entity, len(ids))
+	for i, id := range ids {
+		children[i] = Entity{id, entity.scene}
+	}
+	return children
+}
+
+// WalkDepthFirst calls fn for all descendants of the entity, depth first. The
+// descendants of an entity are skipped if fn returns false for it.
+func (entity *Entity) WalkDepthFirst(fn func(entity *Entity) bool) {
+	for _, child := range entity.Children() {
+		if fn(&child) {
+			child.WalkDepthFirst(fn)
+		}
+	}
+}
+
+// WalkBreadthFirst calls fn for all descendants of the entity, breadth first. The
+// descendants of an entity are skipped if fn returns false for it.
+func (entity *Entity) WalkBreadthFirst(fn func(entity *Entity) bool) {
+	queue := entity.Children()
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if fn(&next) {
+			queue = append(queue, next.Children()...)
+		}
+	}
+}
+
+// SetParent records making the child a child of the parent. See Entity.SetParent.
+func (buffer *CommandBuffer) SetParent(child *Entity, parent *Entity) {
+	resolveChild := buffer.resolve(child)
+	if parent == nil {
+		buffer.record(func() {
+			resolveChild().SetParent(nil)
+		})
+		return
+	}
+	resolveParent := buffer.resolve(parent)
+	buffer.record(func() {
+		resolveChild().SetParent(resolveParent())
+	})
+}
+
+// detach removes the entity with the index from its parent.
+func (scene *Scene) detach(index uint32) {
+	record := &scene.entities[index]
+	if record.parent == 0 {
+		return
+	}
+	id := newEntityID(index, record.generation)
+	parent := &scene.entities[record.parent.Index()]
+	for i, child := range parent.children {
+		if child == id {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	record.parent = 0
+}
+
+// newParents returns the parents of n entities without parents, for acyclic.
+func newParents(n int) []int {
+	parents := make([]int, n)
+	for i := range parents {
+		parents[i] = -1
+	}
+	return parents
+}
+
+// acyclic returns true if no entity is its own ancestor. parents maps the index of every
+// entity to the index of its parent, or to -1 if the entity has no parent.
+func acyclic(parents []int) bool {
+	// Entities are walked up to their root. Entities marked as checked are known to lead
+	// to a root, and meeting an entity marked as visiting means the walk is in a cycle.
+	const (
+		unchecked = iota
+		visiting
+		checked
+	)
+	states := make([]uint8, len(parents))
+	for start := range parents {
+		index := start
+		for index != -1 && states[index] == unchecked {
+			states[index] = visiting
+			index = parents[index]
+		}
+		if index != -1 && states[index] == visiting {
+			return false
+		}
+		for index = start; index != -1 && states[index] == visiting; index = parents[index] {
+			states[index] = checked
+		}
+	}
+	return true
+}
+
+// removeDescendants removes all descendants of the entity with the index, and removes it
+// from its parent.
+func (scene *Scene) removeDescendants(index uint32) {
+	for {
+		children := scene.entities[index].children
+		if len(children) == 0 {
+			break
+		}
+		scene.removeEntity(&Entity{children[len(children)-1], scene})
+	}
+	scene.detach(index)
+}
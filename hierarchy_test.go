@@ -0,0 +1,126 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+// newTree creates the tree root -> (a -> (c, d), b).
+func newTree(scene *ecs.Scene) map[string]ecs.Entity {
+	tree := map[string]ecs.Entity{}
+	for _, name := range []string{"root", "a", "b", "c", "d"} {
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &name)
+		tree[name] = entity
+	}
+	for _, pair := range [][2]string{{"a", "root"}, {"b", "root"}, {"c", "a"}, {"d", "a"}} {
+		child, parent := tree[pair[0]], tree[pair[1]]
+		child.SetParent(&parent)
+	}
+	return tree
+}
+
+func names(entities []ecs.Entity) []string {
+	result := []string{}
+	for _, entity := range entities {
+		name, _ := ecs.GetComponent[string](&entity)
+		result = append(result, *name)
+	}
+	return result
+}
+
+func TestSetParent(t *testing.T) {
+	scene := ecs.Scene{}
+	tree := newTree(&scene)
+	root, a, c := tree["root"], tree["a"], tree["c"]
+
+	parent, ok := a.Parent()
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(parent), subx.CompareEqual(root)))
+	_, ok = root.Parent()
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+	t.Run("Expected correct result", subx.Test(subx.Value(names(root.Children())), subx.DeepEqual([]string{"a", "b"})))
+
+	err := root.SetParent(&c)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	err = a.SetParent(&a)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+	err = c.SetParent(&root)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	t.Run("Expected correct result", subx.Test(subx.Value(names(root.Children())), subx.DeepEqual([]string{"a", "b", "c"})))
+	t.Run("Expected correct result", subx.Test(subx.Value(names(a.Children())), subx.DeepEqual([]string{"d"})))
+
+	c.SetParent(nil)
+	_, ok = c.Parent()
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+	t.Run("Expected correct result", subx.Test(subx.Value(names(root.Children())), subx.DeepEqual([]string{"a", "b"})))
+}
+
+func TestWalk(t *testing.T) {
+	scene := ecs.Scene{}
+	tree := newTree(&scene)
+	root := tree["root"]
+
+	var visited []ecs.Entity
+	root.WalkDepthFirst(func(entity *ecs.Entity) bool {
+		visited = append(visited, *entity)
+		return true
+	})
+	t.Run("Expected correct result", subx.Test(subx.Value(names(visited)), subx.DeepEqual([]string{"a", "c", "d", "b"})))
+
+	visited = nil
+	root.WalkBreadthFirst(func(entity *ecs.Entity) bool {
+		visited = append(visited, *entity)
+		return true
+	})
+	t.Run("Expected correct result", subx.Test(subx.Value(names(visited)), subx.DeepEqual([]string{"a", "b", "c", "d"})))
+
+	visited = nil
+	root.WalkDepthFirst(func(entity *ecs.Entity) bool {
+		visited = append(visited, *entity)
+		return false
+	})
+	t.Run("Expected correct result", subx.Test(subx.Value(names(visited)), subx.DeepEqual([]string{"a", "b"})))
+}
+
+func TestRemoveParent(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		tree := newTree(scene)
+		root, a, b := tree["root"], tree["a"], tree["b"]
+
+		a.Remove()
+		for _, name := range []string{"a", "c", "d"} {
+			entity := tree[name]
+			t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(entity.ID())), subx.CompareEqual(false)))
+		}
+		t.Run("Expected correct result", subx.Test(subx.Value(names(root.Children())), subx.DeepEqual([]string{"b"})))
+
+		// Reused indices do not keep the hierarchy of the removed entities.
+		reused := scene.NewEntity()
+		t.Run("Expected correct result", subx.Test(subx.Value(len(reused.Children())), subx.CompareEqual(0)))
+		_, ok := reused.Parent()
+		t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+
+		scene.Commands().RemoveEntity(&root)
+		scene.Flush()
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(b.ID())), subx.CompareEqual(false)))
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+// componentHooks are the functions called when components of type T are added, set
+// or removed.
+type componentHooks[T any] struct {
+	onAdd    []func(entity Entity, component *T)
+	onSet    []func(entity Entity, component *T)
+	onRemove []func(entity Entity, component *T)
+}
+
+// OnAdd registers a function which is called after a component of type T is added to
+// an entity which did not have one.
+func OnAdd[T any](scene *Scene, fn func(entity Entity, component *T)) {
+	hooks := getPool[T](scene).componentHooks()
+	hooks.onAdd = append(hooks.onAdd, fn)
+}
+
+// OnSet registers a function which is called after a component of type T is
+// overwritten by AddComponent.
+func OnSet[T any](scene *Scene, fn func(entity Entity, component *T)) {
+	hooks := getPool[T](scene).componentHooks()
+	hooks.onSet = append(hooks.onSet, fn)
+}
+
+// OnRemove registers a function which is called after a component of type T is removed
+// from an entity, either by RemoveComponent or by removing the entity. The function is
+// given a copy of the removed component.
+func OnRemove[T any](scene *Scene, fn func(entity Entity, component *T)) {
+	hooks := getPool[T](scene).componentHooks()
+	hooks.onRemove = append(hooks.onRemove, fn)
+}
+
+func (hooks *componentHooks[T]) added(component *Component[T]) {
+	for _, fn := range hooks.onAdd {
+		fn(component.entity, &component.component)
+	}
+}
+
+func (hooks *componentHooks[T]) set(component *Component[T]) {
+	for _, fn := range hooks.onSet {
+		fn(component.entity, &component.component)
+	}
+}
+
+// removed must be called with a copy of the component, after it has been removed.
+func (hooks *componentHooks[T]) removed(component *Component[T]) {
+	for _, fn := range hooks.onRemove {
+		fn(component.entity, &component.component)
+	}
+}
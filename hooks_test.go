@@ -0,0 +1,64 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type rigidBody struct {
+	handle int
+}
+
+func TestHooks(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		bodies := map[int]bool{}
+		set := 0
+		ecs.OnAdd(scene, func(entity ecs.Entity, body *rigidBody) {
+			bodies[body.handle] = true
+		})
+		ecs.OnSet(scene, func(entity ecs.Entity, body *rigidBody) {
+			set++
+		})
+		ecs.OnRemove(scene, func(entity ecs.Entity, body *rigidBody) {
+			_, err := ecs.GetComponent[rigidBody](&entity)
+			t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+			delete(bodies, body.handle)
+		})
+
+		entities := make([]ecs.Entity, 4)
+		for n := range entities {
+			entities[n] = scene.NewEntity()
+			ecs.AddComponent(&entities[n], &position{})
+			ecs.AddComponent(&entities[n], &rigidBody{handle: n})
+		}
+		t.Run("Expected correct result", subx.Test(subx.Value(len(bodies)), subx.CompareEqual(4)))
+
+		ecs.AddComponent(&entities[0], &rigidBody{handle: 0})
+		t.Run("Expected correct result", subx.Test(subx.Value(set), subx.CompareEqual(1)))
+		t.Run("Expected correct result", subx.Test(subx.Value(len(bodies)), subx.CompareEqual(4)))
+
+		ecs.RemoveComponent[rigidBody](&entities[1])
+		entities[2].Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(bodies), subx.DeepEqual(map[int]bool{0: true, 3: true})))
+
+		ecs.RemoveComponent[position](&entities[3])
+		t.Run("Expected correct result", subx.Test(subx.Value(bodies), subx.DeepEqual(map[int]bool{0: true, 3: true})))
+	}
+}
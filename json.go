@@ -0,0 +1,227 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type jsonScene struct {
+	Entities  []jsonEntity               `json:"entities"`
+	Resources map[string]json.RawMessage `json:"resources,omitempty"`
+}
+
+type jsonEntity struct {
+	ID         EntityID                   `json:"id"`
+	Children   []EntityID                 `json:"children,omitempty"`
+	Components map[string]json.RawMessage `json:"components,omitempty"`
+	Relations  map[string][]jsonRelation  `json:"relations,omitempty"`
+}
+
+type jsonRelation struct {
+	Target EntityID        `json:"target"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// MarshalJSON encodes all entities in the scene with their registered components,
+// relations and children, and the registered resources of the scene. Components,
+// relations and resources of types which are not registered with RegisterComponent or
+// RegisterRelation are skipped.
+func (scene *Scene) MarshalJSON() ([]byte, error) {
+	types, relations := registeredTypes(), registeredRelations()
+	encoded := jsonScene{Entities: []jsonEntity{}}
+
+	for index, record := range scene.entities {
+		if !record.alive {
+			continue
+		}
+		entity := Entity{newEntityID(uint32(index), record.generation), scene}
+		encodedEntity := jsonEntity{ID: entity.id, Children: record.children}
+
+		for _, codec := range types {
+			component := codec.component(&entity)
+			if component == nil {
+				continue
+			}
+			data, err := json.Marshal(component)
+			if err != nil {
+				return nil, fmt.Errorf("ecs: encoding component %q: %w", codec.typeName(), err)
+			}
+			if encodedEntity.Components == nil {
+				encodedEntity.Components = make(map[string]json.RawMessage)
+			}
+			encodedEntity.Components[codec.typeName()] = data
+		}
+
+		for _, codec := range relations {
+			var err error
+			codec.relations(&entity, func(target EntityID, value any) {
+				data, encodeErr := json.Marshal(value)
+				if encodeErr != nil {
+					err = fmt.Errorf("ecs: encoding relation %q: %w", codec.typeName(), encodeErr)
+					return
+				}
+				if encodedEntity.Relations == nil {
+					encodedEntity.Relations = make(map[string][]jsonRelation)
+				}
+				name := codec.typeName()
+				encodedEntity.Relations[name] = append(encodedEntity.Relations[name], jsonRelation{target, data})
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		encoded.Entities = append(encoded.Entities, encodedEntity)
+	}
+
+	for _, codec := range types {
+		res := codec.resource(scene)
+		if res == nil {
+			continue
+		}
+		data, err := json.Marshal(res)
+		if err != nil {
+			return nil, fmt.Errorf("ecs: encoding resource %q: %w", codec.typeName(), err)
+		}
+		if encoded.Resources == nil {
+			encoded.Resources = make(map[string]json.RawMessage)
+		}
+		encoded.Resources[codec.typeName()] = data
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON adds the entities and resources encoded by MarshalJSON to the scene.
+// The entities get new IDs, and children and relation targets are remapped to the new
+// IDs. Entity IDs stored inside components are not remapped. An error is returned, and
+// the scene is not changed, if the data contains unregistered type names, references
+// to entities which are not in the data, or entities which are their own ancestor or the
+// child of two entities.
+func (scene *Scene) UnmarshalJSON(data []byte) error {
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+	var decoded jsonScene
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	// Everything is decoded before the scene is changed.
+	positions := make(map[EntityID]int, len(decoded.Entities))
+	for i, entity := range decoded.Entities {
+		if _, ok := positions[entity.ID]; ok {
+			return fmt.Errorf("ecs: entity %d decoded twice", entity.ID)
+		}
+		positions[entity.ID] = i
+	}
+	position := func(id EntityID) (int, error) {
+		i, ok := positions[id]
+		if !ok {
+			return 0, fmt.Errorf("ecs: reference to unknown entity %d", id)
+		}
+		return i, nil
+	}
+
+	var apply []func(entities []Entity)
+	parents := newParents(len(decoded.Entities))
+	for i, entity := range decoded.Entities {
+		i := i
+		for _, name := range sortedKeys(entity.Components) {
+			codec, err := lookupType(name)
+			if err != nil {
+				return err
+			}
+			add, err := codec.decodeComponent(entity.Components[name])
+			if err != nil {
+				return err
+			}
+			apply = append(apply, func(entities []Entity) {
+				add(&entities[i])
+			})
+		}
+		for _, child := range entity.Children {
+			c, err := position(child)
+			if err != nil {
+				return err
+			}
+			if parents[c] != -1 {
+				return fmt.Errorf("ecs: entity %d is the child of two entities", child)
+			}
+			parents[c] = i
+			apply = append(apply, func(entities []Entity) {
+				entities[c].SetParent(&entities[i])
+			})
+		}
+		for _, name := range sortedKeys(entity.Relations) {
+			codec, err := lookupRelation(name)
+			if err != nil {
+				return err
+			}
+			for _, rel := range entity.Relations[name] {
+				target, err := position(rel.Target)
+				if err != nil {
+					return err
+				}
+				add, err := codec.decodeRelation(rel.Data)
+				if err != nil {
+					return err
+				}
+				apply = append(apply, func(entities []Entity) {
+					add(&entities[i], &entities[target])
+				})
+			}
+		}
+	}
+	if !acyclic(parents) {
+		return errors.New("ecs: entity is its own ancestor")
+	}
+
+	var resources []func(scene *Scene)
+	for _, name := range sortedKeys(decoded.Resources) {
+		codec, err := lookupType(name)
+		if err != nil {
+			return err
+		}
+		set, err := codec.decodeResource(decoded.Resources[name])
+		if err != nil {
+			return err
+		}
+		resources = append(resources, set)
+	}
+
+	entities := make([]Entity, len(decoded.Entities))
+	for i := range entities {
+		entities[i] = scene.NewEntity()
+	}
+	for _, fn := range apply {
+		fn(entities)
+	}
+	for _, set := range resources {
+		set(scene)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+// Serialized types need exported fields.
+type transform struct {
+	X, Y float64
+}
+
+type nameTag struct {
+	Name string
+}
+
+type settings struct {
+	Volume int
+}
+
+type follows struct {
+	Distance float64
+}
+
+func init() {
+	ecs.RegisterComponent[transform]("transform")
+	ecs.RegisterComponent[nameTag]("name")
+	ecs.RegisterComponent[settings]("settings")
+	ecs.RegisterRelation[follows]("follows")
+}
+
+// newSaveScene creates a scene with a parent with two children, one following the other.
+func newSaveScene(storage ecs.Storage) *ecs.Scene {
+	scene := ecs.NewScene(storage)
+	removed := scene.NewEntity() // The IDs in the scene do not start at the first index.
+	removed.Remove()
+
+	parent, a, b := scene.NewEntity(), scene.NewEntity(), scene.NewEntity()
+	ecs.AddComponent(&parent, &nameTag{Name: "parent"})
+	ecs.AddComponent(&a, &nameTag{Name: "a"})
+	ecs.AddComponent(&a, &transform{X: 1, Y: 2})
+	ecs.AddComponent(&b, &nameTag{Name: "b"})
+	ecs.AddComponent(&b, &health{hp: 10}) // Not registered
+	b.SetParent(&parent)
+	a.SetParent(&parent)
+	ecs.AddRelation(&b, &a, &follows{Distance: 3})
+	ecs.SetResource(scene, &settings{Volume: 7})
+	return scene
+}
+
+// findByName returns the entity with the name component.
+func findByName(scene *ecs.Scene, name string) ecs.Entity {
+	var found ecs.Entity
+	ecs.Query1(scene, func(entity *ecs.Entity, tag *nameTag) {
+		if tag.Name == name {
+			found = *entity
+		}
+	})
+	return found
+}
+
+func TestSceneJSON(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		data, err := json.Marshal(newSaveScene(storage))
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+
+		loaded := ecs.NewScene(storage)
+		err = json.Unmarshal(data, loaded)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+
+		parent, a, b := findByName(loaded, "parent"), findByName(loaded, "a"), findByName(loaded, "b")
+		t.Run("Expected correct result", subx.Test(subx.Value(parent.Children()), subx.DeepEqual([]ecs.Entity{b, a})))
+
+		tf, err := ecs.GetComponent[transform](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(*tf), subx.CompareEqual(transform{X: 1, Y: 2})))
+		_, err = ecs.GetComponent[health](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+		rel, err := ecs.GetRelation[follows](&b, &a)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(rel.Distance), subx.CompareEqual(3.0)))
+
+		res, ok := ecs.Resource[settings](loaded)
+		t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(res.Volume), subx.CompareEqual(7)))
+
+		// The loaded scene encodes the same entities and resources.
+		loadedData, _ := json.Marshal(loaded)
+		var before, after map[string]any
+		json.Unmarshal(data, &before)
+		json.Unmarshal(loadedData, &after)
+		t.Run("Expected correct result", subx.Test(subx.Value(len(after["entities"].([]any))), subx.CompareEqual(3)))
+		t.Run("Expected correct result", subx.Test(subx.Value(after["resources"]), subx.DeepEqual(before["resources"])))
+	}
+}
+
+func TestSceneJSONErrors(t *testing.T) {
+	scene := ecs.Scene{}
+	for _, data := range []string{
+		`{"entities": [{"id": 1, "components": {"unknown": {}}}]}`,
+		`{"entities": [{"id": 1, "children": [2]}]}`,
+		`{"entities": [{"id": 1, "relations": {"follows": [{"target": 2, "data": {}}]}}]}`,
+		`{"entities": [{"id": 1}, {"id": 1}]}`,
+		`{"entities": [], "resources": {"unknown": {}}}`,
+		// Entities which are children of each other, their own child, or the child of two
+		// entities.
+		`{"entities": [{"id": 1, "children": [2]}, {"id": 2, "children": [1]}]}`,
+		`{"entities": [{"id": 1, "children": [1]}]}`,
+		`{"entities": [{"id": 1, "children": [3]}, {"id": 2, "children": [3]}, {"id": 3}]}`,
+	} {
+		err := json.Unmarshal([]byte(data), &scene)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+	// Nothing is added when loading fails.
+	entity := scene.NewEntity()
+	t.Run("Expected correct result", subx.Test(subx.Value(entity.ID().Index()), subx.CompareEqual(uint32(0))))
+}
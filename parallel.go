@@ -0,0 +1,114 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "sync"
+
+const defaultChunkSize = 1024
+
+// SetChunkSize sets the number of components each goroutine processes at a time in
+// ParallelEach. The default is 1024.
+func (scene *Scene) SetChunkSize(n int) {
+	scene.chunkSize = n
+}
+
+// ParallelEach calls fn for every component of type T. The components are split into
+// chunks, which are processed by several goroutines at the same time, so fn must be safe
+// for concurrent use. Adding or removing components and entities returns an error while
+// ParallelEach is running, so record the changes in a CommandBuffer instead.
+func ParallelEach[T any](scene *Scene, fn func(entity *Entity, component *T)) {
+	componentPool, ok := findPool[T](scene)
+	if !ok {
+		return
+	}
+	chunks := componentPool.chunks([]uint32{componentPool.componentID()})
+	parallelChunks(scene, chunks, func(component *Component[T]) {
+		fn(&component.entity, &component.component)
+	})
+}
+
+// ParallelEach2 calls fn for every entity with components of type A and B, in the same
+// way as ParallelEach.
+func ParallelEach2[A, B any](scene *Scene, fn func(entity *Entity, a *A, b *B)) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	if !okA || !okB {
+		return
+	}
+	chunks := poolA.chunks([]uint32{poolA.componentID(), poolB.componentID()})
+	parallelChunks(scene, chunks, func(component *Component[A]) {
+		if b := poolB.get(&component.entity); b != nil {
+			fn(&component.entity, &component.component, b)
+		}
+	})
+}
+
+// parallelChunks splits the chunks into smaller chunks of the chunk size of the scene,
+// and calls visit for every component in them on the workers of the scene.
+func parallelChunks[T any](scene *Scene, chunks [][]Component[T], visit func(component *Component[T])) {
+	chunkSize := scene.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	var jobs [][]Component[T]
+	for _, chunk := range chunks {
+		for len(chunk) > chunkSize {
+			jobs = append(jobs, chunk[:chunkSize])
+			chunk = chunk[chunkSize:]
+		}
+		if len(chunk) > 0 {
+			jobs = append(jobs, chunk)
+		}
+	}
+
+	// ParallelEach may be called by a system updated in parallel, where the scene is
+	// already marked.
+	if !scene.parallel {
+		scene.parallel = true
+		defer func() { scene.parallel = false }()
+	}
+
+	workers := scene.workerCount()
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	if workers <= 1 {
+		for _, job := range jobs {
+			for i := range job {
+				visit(&job[i])
+			}
+		}
+		return
+	}
+
+	channel := make(chan []Component[T])
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range channel {
+				for i := range job {
+					visit(&job[i])
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		channel <- job
+	}
+	close(channel)
+	wg.Wait()
+}
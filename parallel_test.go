@@ -0,0 +1,289 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type positionSystem struct {
+	ecs.System
+}
+
+func (sys *positionSystem) Update(dt float64) {
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, p *position) {
+		p.x++
+	})
+}
+
+type velocitySystem struct {
+	ecs.System
+	addErr error
+}
+
+func (sys *velocitySystem) Update(dt float64) {
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, v *velocity) {
+		v.x++
+	})
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, v *velocity) {
+		if sys.addErr == nil {
+			sys.addErr = ecs.AddComponent(entity, &frozen{})
+			ecs.DeferAddComponent(sys.Commands(), entity, &frozen{})
+		}
+	})
+}
+
+type sumSystem struct {
+	ecs.System
+	sum float64
+}
+
+func (sys *sumSystem) Update(dt float64) {
+	sys.sum = 0
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, p *position) {
+		sys.sum += p.x
+	})
+}
+
+func TestParallelSystems(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		scene.SetWorkers(4)
+		for n := 0; n < 1000; n++ {
+			entity := scene.NewEntity()
+			ecs.AddComponent(&entity, &position{})
+			ecs.AddComponent(&entity, &velocity{})
+		}
+
+		positions := &positionSystem{}
+		velocities := &velocitySystem{}
+		sum := &sumSystem{}
+		scene.AddSystem(positions, ecs.Writes[position]())
+		scene.AddSystem(velocities, ecs.Writes[velocity](), ecs.Writes[frozen]())
+		scene.AddSystem(sum, ecs.Reads[position]())
+
+		for i := 0; i < 3; i++ {
+			scene.Update(0)
+		}
+		t.Run("Expected correct result", subx.Test(subx.Value(sum.sum), subx.CompareEqual(3000.0)))
+		t.Run("Expected correct result", subx.Test(subx.Value(velocities.addErr), subx.CompareNotEqual[error](nil)))
+
+		count := 0
+		ecs.Query1(scene, func(entity *ecs.Entity, f *frozen) {
+			count++
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(count), subx.CompareEqual(1)))
+	}
+}
+
+type meetSystem struct {
+	ecs.System
+	send, receive chan bool
+	met           bool
+}
+
+func (sys *meetSystem) Update(dt float64) {
+	sys.send <- true
+	select {
+	case <-sys.receive:
+		sys.met = true
+	case <-time.After(time.Second):
+	}
+}
+
+func TestParallelSystemsConcurrent(t *testing.T) {
+	scene := ecs.Scene{}
+	scene.SetWorkers(2)
+	a, b := make(chan bool, 1), make(chan bool, 1)
+	sys1 := &meetSystem{send: a, receive: b}
+	sys2 := &meetSystem{send: b, receive: a}
+	scene.AddSystem(sys1, ecs.Writes[position]())
+	scene.AddSystem(sys2, ecs.Writes[velocity]())
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(sys1.met), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sys2.met), subx.CompareEqual(true)))
+}
+
+// undeclaredSystem meets the other system, so that their queries overlap, and then
+// queries with filters of the type F, which it has not declared and which has not been
+// used in the scene.
+type undeclaredSystem[T, F any] struct {
+	meetSystem
+	visited, changed int
+}
+
+func (sys *undeclaredSystem[T, F]) Update(dt float64) {
+	sys.meetSystem.Update(dt)
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, c *T) {
+		sys.visited++
+	}, ecs.Without[F](), ecs.Optional[F]())
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, c *T) {
+		sys.changed++
+	}, ecs.Changed[F](sys))
+}
+
+func TestParallelSystemsUndeclaredFilter(t *testing.T) {
+	type frozenA struct{ since int }
+	type frozenB struct{ since int }
+
+	scene := ecs.Scene{}
+	scene.SetWorkers(2)
+	entity := scene.NewEntity()
+	ecs.AddComponent(&entity, &position{})
+	ecs.AddComponent(&entity, &velocity{})
+	a, b := make(chan bool, 1), make(chan bool, 1)
+	sys1 := &undeclaredSystem[position, frozenA]{meetSystem: meetSystem{send: a, receive: b}}
+	sys2 := &undeclaredSystem[velocity, frozenB]{meetSystem: meetSystem{send: b, receive: a}}
+	scene.AddSystem(sys1, ecs.Writes[position]())
+	scene.AddSystem(sys2, ecs.Writes[velocity]())
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(sys1.met), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sys1.visited), subx.CompareEqual(1)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sys2.visited), subx.CompareEqual(1)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sys1.changed+sys2.changed), subx.CompareEqual(0)))
+}
+
+type spawned struct {
+	n int
+}
+
+type spawnerSystem struct {
+	ecs.System
+}
+
+func (sys *spawnerSystem) Update(dt float64) {
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, p *position) {
+		ecs.DeferAddComponent(sys.Commands(), entity, &spawned{})
+	})
+}
+
+type watcherSystem struct {
+	ecs.System
+	added int
+}
+
+func (sys *watcherSystem) Update(dt float64) {
+	ecs.Query1(sys.Scene(), func(entity *ecs.Entity, s *spawned) {
+		sys.added++
+	}, ecs.Added[spawned](sys))
+}
+
+func TestParallelSystemsChanges(t *testing.T) {
+	// Changes flushed from the command buffer of a system are seen by the systems updated
+	// in parallel with it in the next frame, instead of in the same frame when the systems
+	// are updated one by one.
+	for _, workers := range []int{1, 2} {
+		scene := ecs.Scene{}
+		scene.SetWorkers(workers)
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		watcher := &watcherSystem{}
+		scene.AddSystem(&spawnerSystem{}, ecs.Writes[position]())
+		scene.AddSystem(watcher, ecs.Reads[spawned]())
+
+		for i := 0; i < 3; i++ {
+			scene.Update(0)
+		}
+		t.Run("Expected correct result", subx.Test(subx.Value(watcher.added), subx.CompareEqual(1)))
+	}
+}
+
+func TestParallelSystemsConflicting(t *testing.T) {
+	scene := ecs.Scene{}
+	scene.SetWorkers(2)
+	a, b := make(chan bool, 1), make(chan bool, 1)
+	sys1 := &meetSystem{send: a, receive: b}
+	sys2 := &meetSystem{send: b, receive: a}
+	scene.AddSystem(sys1, ecs.Writes[position]())
+	scene.AddSystem(sys2, ecs.Reads[position]())
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(sys1.met), subx.CompareEqual(false)))
+}
+
+func TestParallelEach(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		scene.SetWorkers(4)
+		scene.SetChunkSize(100)
+		entities := make([]ecs.Entity, 10000)
+		for n := range entities {
+			entities[n] = scene.NewEntity()
+			ecs.AddComponent(&entities[n], &position{x: float64(n)})
+			if n%2 == 0 {
+				ecs.AddComponent(&entities[n], &velocity{x: 1})
+			}
+			if n%3 == 0 {
+				ecs.AddComponent(&entities[n], &health{})
+			}
+		}
+
+		ecs.ParallelEach(scene, func(entity *ecs.Entity, p *position) {
+			p.y = p.x * 2
+		})
+		ecs.ParallelEach2(scene, func(entity *ecs.Entity, p *position, v *velocity) {
+			p.x += v.x
+			if int(p.x)%10 == 1 {
+				ecs.DeferAddComponent(scene.Commands(), entity, &frozen{})
+			}
+		})
+
+		var addErr error
+		ecs.ParallelEach(scene, func(entity *ecs.Entity, h *health) {
+			if entity.ID() == entities[0].ID() {
+				addErr = ecs.RemoveComponent[health](entity)
+			}
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(addErr), subx.CompareNotEqual[error](nil)))
+
+		scene.Flush()
+		for n := range entities {
+			p, _ := ecs.GetComponent[position](&entities[n])
+			expected := float64(n)
+			if n%2 == 0 {
+				expected++
+			}
+			t.Run("Expected correct result", subx.Test(subx.Value(p.x), subx.CompareEqual(expected)))
+			t.Run("Expected correct result", subx.Test(subx.Value(p.y), subx.CompareEqual(float64(2*n))))
+		}
+
+		count := 0
+		ecs.Query1(scene, func(entity *ecs.Entity, f *frozen) {
+			count++
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(count), subx.CompareEqual(1000)))
+	}
+}
+
+func BenchmarkParallelEach(b *testing.B) {
+	scene := ecs.Scene{}
+	for n := 0; n < 100000; n++ {
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ecs.ParallelEach(&scene, func(entity *ecs.Entity, p *position) {
+			p.x++
+		})
+	}
+}
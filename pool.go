@@ -18,55 +18,158 @@ const (
 	increaseFactor    = 2
 	decreaseFactor    = 2
 	decreaseThreshold = 3
+
+	pageBits = 10
+	pageSize = 1 << pageBits
+	pageMask = pageSize - 1
 )
 
+// pool is a sparse set of components. The components are stored densely, and the sparse
+// array maps entity indices to positions in the dense array. The sparse array is split
+// into pages, which are allocated when the first entity in the page gets a component.
 type pool[T any] struct {
+	id         uint32
 	components []Component[T]
-	indicies   map[uint32]uint32
+	sparse     [][]uint32 // entity index -> component index + 1, 0 when missing
+	hooks      componentHooks[T]
+	removed    removalLog
 }
 
+// poolInterface is implemented by the storage of all component types, independent of the type.
 type poolInterface interface {
 	remove(entity *Entity) bool
+	componentID() uint32
+	removals() *removalLog
+	// capture returns the component of the entity as a prefab component, or nil if the
+	// entity has no component in the pool.
+	capture(entity *Entity) PrefabComponent
+}
+
+// typedPool is the storage of components of type T. It is implemented by pool and archetypePool.
+type typedPool[T any] interface {
+	poolInterface
+	add(entity *Entity, data *T)
+	get(entity *Entity) *T
+	component(entity *Entity) *Component[T]
+	// chunks returns slices with all components of entities which may have components
+	// of all the types ids.
+	chunks(ids []uint32) [][]Component[T]
+	componentHooks() *componentHooks[T]
+	removals() *removalLog
+}
+
+// densePool is implemented by pools storing all their components in a single slice.
+type densePool interface {
+	poolInterface
+	len() int
+	entity(index int) *Entity
+}
+
+func newPool[T any](id uint32) *pool[T] {
+	return &pool[T]{id: id}
+}
+
+func (p *pool[T]) componentID() uint32 {
+	return p.id
+}
+
+func (p *pool[T]) componentHooks() *componentHooks[T] {
+	return &p.hooks
+}
+
+func (p *pool[T]) removals() *removalLog {
+	return &p.removed
+}
+
+func (p *pool[T]) capture(entity *Entity) PrefabComponent {
+	return capture[T](p, entity)
+}
+
+// index returns the position of the component of the entity with the index in the dense array.
+func (p *pool[T]) index(entityIndex uint32) (uint32, bool) {
+	page := entityIndex >> pageBits
+	if page >= uint32(len(p.sparse)) || p.sparse[page] == nil {
+		return 0, false
+	}
+	index := p.sparse[page][entityIndex&pageMask]
+	return index - 1, index != 0
+}
+
+func (p *pool[T]) setIndex(entityIndex uint32, index uint32) {
+	page := entityIndex >> pageBits
+	for page >= uint32(len(p.sparse)) {
+		p.sparse = append(p.sparse, nil)
+	}
+	if p.sparse[page] == nil {
+		p.sparse[page] = make([]uint32, pageSize)
+	}
+	p.sparse[page][entityIndex&pageMask] = index + 1
+}
+
+func (p *pool[T]) clearIndex(entityIndex uint32) {
+	p.sparse[entityIndex>>pageBits][entityIndex&pageMask] = 0
 }
 
 func (p *pool[T]) add(entity *Entity, data *T) {
-	if index, ok := p.indicies[entity.id]; ok {
-		p.components[index] = Component[T]{entity, *data}
+	if index, ok := p.index(entity.id.Index()); ok {
+		p.components[index].set(data)
+		p.hooks.set(&p.components[index])
+		return
 	}
 
 	length := len(p.components)
 	if cap(p.components)-length == 0 {
+		capacity := length * increaseFactor
 		if length == 0 {
-			p.components = []Component[T]{{entity, *data}}
-			p.indicies[entity.id] = 0
-			return
+			capacity = 1
 		}
-		newItems := make([]Component[T], length+1, length*increaseFactor)
+		newItems := make([]Component[T], length+1, capacity)
 		copy(newItems, p.components)
 		p.components = newItems
-		p.components[length] = Component[T]{entity, *data}
-		p.indicies[entity.id] = uint32(length)
-		return
+	} else {
+		p.components = p.components[:length+1]
 	}
-	p.components = p.components[:length+1]
-	p.components[length] = Component[T]{entity, *data}
-	p.indicies[entity.id] = uint32(length)
+	p.components[length] = newComponent(entity, data)
+	p.setIndex(entity.id.Index(), uint32(length))
+	entity.scene.entities[entity.id.Index()].signature.set(p.id)
+	p.hooks.added(&p.components[length])
 }
 
 func (p *pool[T]) get(entity *Entity) *T {
-	index, ok := p.indicies[entity.id]
+	if component := p.component(entity); component != nil {
+		return &component.component
+	}
+	return nil
+}
+
+func (p *pool[T]) component(entity *Entity) *Component[T] {
+	index, ok := p.index(entity.id.Index())
 	if !ok {
 		return nil
 	}
-	return p.components[index].Component()
+	return &p.components[index]
+}
+
+func (p *pool[T]) chunks(ids []uint32) [][]Component[T] {
+	return [][]Component[T]{p.components}
+}
+
+func (p *pool[T]) len() int {
+	return len(p.components)
+}
+
+func (p *pool[T]) entity(index int) *Entity {
+	return p.components[index].Entity()
 }
 
 func (p *pool[T]) remove(entity *Entity) bool {
-	index, ok := p.indicies[entity.id]
+	index, ok := p.index(entity.id.Index())
 	if !ok {
 		return false
 	}
-	delete(p.indicies, entity.id)
+	p.clearIndex(entity.id.Index())
+	removed := p.components[index]
+	entity.scene.entities[entity.id.Index()].signature.clear(p.id)
 
 	length := len(p.components)
 	p.components[index] = p.components[length-1]
@@ -74,7 +177,7 @@ func (p *pool[T]) remove(entity *Entity) bool {
 	length--
 
 	if uint32(length) > index {
-		p.indicies[p.components[index].Entity().id] = index
+		p.setIndex(p.components[index].entity.id.Index(), index)
 	}
 
 	if length*decreaseThreshold < cap(p.components) {
@@ -83,5 +186,7 @@ func (p *pool[T]) remove(entity *Entity) bool {
 		p.components = newItems
 	}
 
+	p.removed.add(removed.entity)
+	p.hooks.removed(&removed)
 	return true
 }
@@ -0,0 +1,171 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "reflect"
+
+// Copier is implemented by components which must be copied deeply when they are copied
+// to new entities by prefabs, Clone and MoveEntity, like components containing pointers,
+// slices or maps. Other components are copied by value.
+type Copier[T any] interface {
+	Copy() T
+}
+
+func copyComponent[T any](component *T) T {
+	if copier, ok := any(component).(Copier[T]); ok {
+		return copier.Copy()
+	}
+	return *component
+}
+
+// PrefabComponent is a component of a prefab, or an override of a component when a
+// prefab is instantiated.
+type PrefabComponent interface {
+	componentType() reflect.Type
+	// add adds a copy of the component to the entity.
+	add(entity *Entity)
+}
+
+type prefabComponent[T any] struct {
+	component T
+}
+
+// Override returns a copy of the component which replaces the component of the same type
+// in the prefab when it is instantiated, or is added if the prefab has no such component.
+func Override[T any](component *T) PrefabComponent {
+	return prefabComponent[T]{copyComponent(component)}
+}
+
+func (prefabComponent[T]) componentType() reflect.Type {
+	return reflect.TypeOf((*T)(nil))
+}
+
+func (p prefabComponent[T]) add(entity *Entity) {
+	component := copyComponent(&p.component)
+	getPool[T](entity.scene).add(entity, &component)
+}
+
+// internalComponent is implemented by components used internally by the scene, which are
+// not copied to prefabs.
+type internalComponent interface {
+	internalComponent()
+}
+
+// capture returns the component of type T of the entity as a prefab component, or nil if
+// the entity has no such component.
+func capture[T any](componentPool typedPool[T], entity *Entity) PrefabComponent {
+	if _, ok := any((*T)(nil)).(internalComponent); ok {
+		return nil
+	}
+	component := componentPool.get(entity)
+	if component == nil {
+		return nil
+	}
+	return Override(component)
+}
+
+// captureComponents returns copies of the components of the entity as prefab components.
+func (scene *Scene) captureComponents(entity *Entity) []PrefabComponent {
+	var components []PrefabComponent
+	for _, componentPool := range scene.componentPools {
+		if component := componentPool.capture(entity); component != nil {
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+// Prefab is a template of components, and optionally children, which is instantiated as
+// new entities with copies of the components. Entity IDs stored in components are not
+// changed when they are copied.
+type Prefab struct {
+	components []PrefabComponent
+	children   []*Prefab
+}
+
+// NewPrefab returns an empty prefab.
+func NewPrefab() *Prefab {
+	return &Prefab{}
+}
+
+// NewPrefabFromEntity returns a prefab with copies of the components and tags of the
+// entity, and prefabs of its children. Relations are not copied. An error is returned if
+// the entity is deleted.
+func NewPrefabFromEntity(entity *Entity) (*Prefab, error) {
+	if !entity.alive() {
+		return nil, ErrEntityDead
+	}
+	prefab := &Prefab{components: entity.scene.captureComponents(entity)}
+	for _, child := range entity.Children() {
+		childPrefab, _ := NewPrefabFromEntity(&child)
+		prefab.children = append(prefab.children, childPrefab)
+	}
+	return prefab, nil
+}
+
+// SetPrefabComponent sets a copy of the component in the prefab, and overwrites if a
+// component of this type is already set.
+func SetPrefabComponent[T any](prefab *Prefab, component *T) {
+	prefab.set(Override(component))
+}
+
+func (prefab *Prefab) set(component PrefabComponent) {
+	for i, c := range prefab.components {
+		if c.componentType() == component.componentType() {
+			prefab.components[i] = component
+			return
+		}
+	}
+	prefab.components = append(prefab.components, component)
+}
+
+// AddChild adds a prefab which is instantiated as a child each time the prefab is
+// instantiated.
+func (prefab *Prefab) AddChild(child *Prefab) {
+	prefab.children = append(prefab.children, child)
+}
+
+// Instantiate creates a new entity with copies of the components of the prefab, and
+// children instantiated from the child prefabs. The overrides replace the components of
+// the same types in the new entity, but not in its children. An error is returned if
+// systems are updated in parallel.
+func (scene *Scene) Instantiate(prefab *Prefab, overrides ...PrefabComponent) (Entity, error) {
+	if err := scene.structuralError(); err != nil {
+		return Entity{}, err
+	}
+	entity := scene.NewEntity()
+	for _, component := range prefab.components {
+		if !overridden(component, overrides) {
+			component.add(&entity)
+		}
+	}
+	for _, component := range overrides {
+		component.add(&entity)
+	}
+	for _, childPrefab := range prefab.children {
+		child, _ := scene.Instantiate(childPrefab)
+		child.SetParent(&entity)
+	}
+	return entity, nil
+}
+
+func overridden(component PrefabComponent, overrides []PrefabComponent) bool {
+	for _, override := range overrides {
+		if override.componentType() == component.componentType() {
+			return true
+		}
+	}
+	return false
+}
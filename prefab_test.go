@@ -0,0 +1,90 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+// loot is copied deeply, so that instances do not share the items.
+type loot struct {
+	items []string
+}
+
+func (l *loot) Copy() loot {
+	return loot{append([]string(nil), l.items...)}
+}
+
+func TestPrefab(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		weapon := ecs.NewPrefab()
+		ecs.SetPrefabComponent(weapon, &nameTag{Name: "sword"})
+		enemy := ecs.NewPrefab()
+		ecs.SetPrefabComponent(enemy, &health{hp: 10})
+		ecs.SetPrefabComponent(enemy, &loot{items: []string{"coin"}})
+		enemy.AddChild(weapon)
+
+		a, err := scene.Instantiate(enemy)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		b, _ := scene.Instantiate(enemy, ecs.Override(&health{hp: 20}))
+
+		hpA, _ := ecs.GetComponent[health](&a)
+		hpB, _ := ecs.GetComponent[health](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(hpA.hp), subx.CompareEqual(10)))
+		t.Run("Expected correct result", subx.Test(subx.Value(hpB.hp), subx.CompareEqual(20)))
+
+		// The components are copied deeply.
+		lootA, _ := ecs.GetComponent[loot](&a)
+		lootA.items[0] = "gem"
+		lootB, _ := ecs.GetComponent[loot](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(lootB.items), subx.DeepEqual([]string{"coin"})))
+
+		children := b.Children()
+		t.Run("Expected correct result", subx.Test(subx.Value(len(children)), subx.CompareEqual(1)))
+		name, _ := ecs.GetComponent[nameTag](&children[0])
+		t.Run("Expected correct result", subx.Test(subx.Value(name.Name), subx.CompareEqual("sword")))
+	}
+}
+
+func TestPrefabFromEntity(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		parent, child, other := scene.NewEntity(), scene.NewEntity(), scene.NewEntity()
+		ecs.AddComponent(&parent, &position{x: 1, y: 2})
+		ecs.AddComponent(&child, &health{hp: 5})
+		child.SetParent(&parent)
+		ecs.AddRelation(&parent, &other, &likes{amount: 1})
+
+		prefab, err := ecs.NewPrefabFromEntity(&parent)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		// Changes to the entity after the prefab is created are not in the prefab.
+		p, _ := ecs.GetComponent[position](&parent)
+		p.x = 10
+
+		entity, _ := scene.Instantiate(prefab)
+		pos, _ := ecs.GetComponent[position](&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(*pos), subx.CompareEqual(position{x: 1, y: 2})))
+		t.Run("Expected correct result", subx.Test(subx.Value(len(entity.Children())), subx.CompareEqual(1)))
+		t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.Targets[likes](&entity))), subx.CompareEqual(0)))
+
+		other.Remove()
+		_, err = ecs.NewPrefabFromEntity(&other)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+}
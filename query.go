@@ -0,0 +1,246 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+// Query1 calls fn for every entity with a component of type A,
+// matching all the filters.
+func Query1[A any](scene *Scene, fn func(entity *Entity, a *A), filters ...Filter) {
+	poolA, ok := findPool[A](scene)
+	if !ok {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		fn(entity, a)
+	})
+}
+
+// Query2 calls fn for every entity with components of type A and B,
+// matching all the filters.
+func Query2[A, B any](scene *Scene, fn func(entity *Entity, a *A, b *B), filters ...Filter) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	if !okA || !okB {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA, poolB}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		b := poolB.get(entity)
+		if b == nil {
+			return
+		}
+		fn(entity, a, b)
+	})
+}
+
+// Query3 calls fn for every entity with components of type A, B and C,
+// matching all the filters.
+func Query3[A, B, C any](scene *Scene, fn func(entity *Entity, a *A, b *B, c *C), filters ...Filter) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	poolC, okC := findPool[C](scene)
+	if !okA || !okB || !okC {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA, poolB, poolC}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		b := poolB.get(entity)
+		if b == nil {
+			return
+		}
+		c := poolC.get(entity)
+		if c == nil {
+			return
+		}
+		fn(entity, a, b, c)
+	})
+}
+
+// Query4 calls fn for every entity with components of type A, B, C and D,
+// matching all the filters.
+func Query4[A, B, C, D any](scene *Scene, fn func(entity *Entity, a *A, b *B, c *C, d *D), filters ...Filter) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	poolC, okC := findPool[C](scene)
+	poolD, okD := findPool[D](scene)
+	if !okA || !okB || !okC || !okD {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA, poolB, poolC, poolD}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		b := poolB.get(entity)
+		if b == nil {
+			return
+		}
+		c := poolC.get(entity)
+		if c == nil {
+			return
+		}
+		d := poolD.get(entity)
+		if d == nil {
+			return
+		}
+		fn(entity, a, b, c, d)
+	})
+}
+
+// Query5 calls fn for every entity with components of type A, B, C, D and E,
+// matching all the filters.
+func Query5[A, B, C, D, E any](scene *Scene, fn func(entity *Entity, a *A, b *B, c *C, d *D, e *E), filters ...Filter) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	poolC, okC := findPool[C](scene)
+	poolD, okD := findPool[D](scene)
+	poolE, okE := findPool[E](scene)
+	if !okA || !okB || !okC || !okD || !okE {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA, poolB, poolC, poolD, poolE}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		b := poolB.get(entity)
+		if b == nil {
+			return
+		}
+		c := poolC.get(entity)
+		if c == nil {
+			return
+		}
+		d := poolD.get(entity)
+		if d == nil {
+			return
+		}
+		e := poolE.get(entity)
+		if e == nil {
+			return
+		}
+		fn(entity, a, b, c, d, e)
+	})
+}
+
+// Query6 calls fn for every entity with components of type A, B, C, D, E and F,
+// matching all the filters.
+func Query6[A, B, C, D, E, F any](scene *Scene, fn func(entity *Entity, a *A, b *B, c *C, d *D, e *E, f *F), filters ...Filter) {
+	poolA, okA := findPool[A](scene)
+	poolB, okB := findPool[B](scene)
+	poolC, okC := findPool[C](scene)
+	poolD, okD := findPool[D](scene)
+	poolE, okE := findPool[E](scene)
+	poolF, okF := findPool[F](scene)
+	if !okA || !okB || !okC || !okD || !okE || !okF {
+		return
+	}
+	query(scene, filters, []poolInterface{poolA, poolB, poolC, poolD, poolE, poolF}, func(entity *Entity) {
+		a := poolA.get(entity)
+		if a == nil {
+			return
+		}
+		b := poolB.get(entity)
+		if b == nil {
+			return
+		}
+		c := poolC.get(entity)
+		if c == nil {
+			return
+		}
+		d := poolD.get(entity)
+		if d == nil {
+			return
+		}
+		e := poolE.get(entity)
+		if e == nil {
+			return
+		}
+		f := poolF.get(entity)
+		if f == nil {
+			return
+		}
+		fn(entity, a, b, c, d, e, f)
+	})
+}
+
+// query calls visit for each entity matching the filters, which may have components in
+// all the pools. With PoolStorage the smallest pool is iterated, and visit joins against
+// the other pools, skipping entities whose signature is missing a component. With
+// ArchetypeStorage only archetypes with all the component types are iterated. Tags are
+// only stored in the signatures, so all entities are iterated when all the component
+// types are tags. The queries visit no entities if a component type has never been used
+// in the scene, since creating its pool is not safe while systems are updated in parallel.
+func query(scene *Scene, filters []Filter, pools []poolInterface, visit func(entity *Entity)) {
+	matchers := make([]func(entity *Entity) bool, len(filters))
+	for i, filter := range filters {
+		matchers[i] = filter.matcher(scene)
+	}
+	var required signature
+	ids := make([]uint32, len(pools))
+	for i, p := range pools {
+		ids[i] = p.componentID()
+		required.set(ids[i])
+	}
+	visitMatching := func(entity *Entity) {
+		if scene.entities[entity.id.Index()].signature.contains(required) && matchAll(matchers, entity) {
+			visit(entity)
+		}
+	}
+
+	var smallest densePool
+	if scene.archetypes != nil {
+		if columns := columnIDs(scene, ids); len(columns) > 0 {
+			scene.archetypes.query(columns, visitMatching)
+			return
+		}
+	} else {
+		for _, p := range pools {
+			if dense, ok := p.(densePool); ok && (smallest == nil || dense.len() < smallest.len()) {
+				smallest = dense
+			}
+		}
+	}
+	if smallest == nil {
+		for index := range scene.entities {
+			if scene.entities[index].alive {
+				entity := scene.entityAt(uint32(index))
+				visitMatching(&entity)
+			}
+		}
+		return
+	}
+	for i := 0; i < smallest.len(); i++ {
+		visitMatching(smallest.entity(i))
+	}
+}
+
+func matchAll(matchers []func(entity *Entity) bool, entity *Entity) bool {
+	for _, match := range matchers {
+		if !match(entity) {
+			return false
+		}
+	}
+	return true
+}
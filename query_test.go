@@ -0,0 +1,116 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type position struct {
+	x, y float64
+}
+
+type velocity struct {
+	x, y float64
+}
+
+type health struct {
+	hp int
+}
+
+func TestQuery2(t *testing.T) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 10)
+	for n := 0; n < 10; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{x: float64(n)})
+		if n%2 == 0 {
+			ecs.AddComponent(&entities[n], &velocity{x: 1})
+		}
+	}
+
+	count := 0
+	ecs.Query2(&scene, func(entity *ecs.Entity, p *position, v *velocity) {
+		p.x += v.x
+		count++
+	})
+	t.Run("Expected correct result", subx.Test(subx.Value(count), subx.CompareEqual(5)))
+
+	for n := 0; n < 10; n++ {
+		p, _ := ecs.GetComponent[position](&entities[n])
+		expected := float64(n)
+		if n%2 == 0 {
+			expected++
+		}
+		t.Run("Expected correct result", subx.Test(subx.Value(p.x), subx.CompareEqual(expected)))
+	}
+}
+
+func TestQuery3(t *testing.T) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 10)
+	for n := 0; n < 10; n++ {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &position{})
+		ecs.AddComponent(&entities[n], &velocity{})
+		if n < 3 {
+			ecs.AddComponent(&entities[n], &health{hp: n})
+		}
+	}
+	ecs.RemoveComponent[velocity](&entities[0])
+
+	sum := 0
+	count := 0
+	ecs.Query3(&scene, func(entity *ecs.Entity, p *position, v *velocity, h *health) {
+		sum += h.hp
+		count++
+	})
+	t.Run("Expected correct result", subx.Test(subx.Value(count), subx.CompareEqual(2)))
+	t.Run("Expected correct result", subx.Test(subx.Value(sum), subx.CompareEqual(3)))
+}
+
+func TestQueryEntity(t *testing.T) {
+	scene := ecs.Scene{}
+	entity := scene.NewEntity()
+	ecs.AddComponent(&entity, &position{x: 3})
+	ecs.AddComponent(&entity, &velocity{x: 4})
+
+	ecs.Query2(&scene, func(e *ecs.Entity, p *position, v *velocity) {
+		result, err := ecs.GetComponent[position](e)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(result.x), subx.CompareEqual(3.0)))
+	})
+}
+
+func BenchmarkQuery2(b *testing.B) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 100)
+	for i := 0; i < len(entities); i++ {
+		entities[i] = scene.NewEntity()
+		ecs.AddComponent(&entities[i], &position{})
+		ecs.AddComponent(&entities[i], &velocity{x: 1, y: 1})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ecs.Query2(&scene, func(entity *ecs.Entity, p *position, v *velocity) {
+			p.x += v.x
+			p.y += v.y
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// registry maps names to component and relation types, for serialization. It is shared
+// by all scenes.
+var registry = struct {
+	sync.RWMutex
+	types     map[string]typeCodec
+	names     map[reflect.Type]string
+	relations map[string]relationCodec
+}{
+	types:     make(map[string]typeCodec),
+	names:     make(map[reflect.Type]string),
+	relations: make(map[string]relationCodec),
+}
+
+// RegisterComponent registers the type T with the name, so that components and resources
+// of type T are serialized. Components and resources of types which are not registered
+// are skipped when a scene is serialized. The fields of T are serialized like with
+// encoding/json, so only exported fields are serialized. Tags are registered in the
+// same way, and are saved as components without data.
+//
+// RegisterComponent panics if the name or the type is already registered.
+func RegisterComponent[T any](name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registerName(name, reflect.TypeOf((*T)(nil)))
+	registry.types[name] = componentCodec[T]{name, valuesOf[T]{binaryFormatOf[T]()}}
+}
+
+// RegisterRelation registers the relation type R with the name, so that relations of type
+// R are serialized. See RegisterComponent.
+func RegisterRelation[R any](name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registerName(name, reflect.TypeOf((*relation[R])(nil)))
+	registry.relations[name] = relationCodecOf[R]{name, valuesOf[R]{binaryFormatOf[R]()}}
+}
+
+// registerName must be called with the registry locked.
+func registerName(name string, registeredType reflect.Type) {
+	_, component := registry.types[name]
+	_, relation := registry.relations[name]
+	if component || relation {
+		panic(fmt.Sprintf("ecs: type name %q registered twice", name))
+	}
+	if _, ok := registry.names[registeredType]; ok {
+		panic(fmt.Sprintf("ecs: type %s registered twice", registeredType))
+	}
+	registry.names[registeredType] = name
+}
+
+// registeredTypes returns the registered component types, sorted by name.
+func registeredTypes() []typeCodec {
+	registry.RLock()
+	defer registry.RUnlock()
+	codecs := make([]typeCodec, 0, len(registry.types))
+	for _, codec := range registry.types {
+		codecs = append(codecs, codec)
+	}
+	sort.Slice(codecs, func(i, j int) bool { return codecs[i].typeName() < codecs[j].typeName() })
+	return codecs
+}
+
+// registeredRelations returns the registered relation types, sorted by name.
+func registeredRelations() []relationCodec {
+	registry.RLock()
+	defer registry.RUnlock()
+	codecs := make([]relationCodec, 0, len(registry.relations))
+	for _, codec := range registry.relations {
+		codecs = append(codecs, codec)
+	}
+	sort.Slice(codecs, func(i, j int) bool { return codecs[i].typeName() < codecs[j].typeName() })
+	return codecs
+}
+
+func lookupType(name string) (typeCodec, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	codec, ok := registry.types[name]
+	if !ok {
+		return nil, fmt.Errorf("ecs: unknown component type %q, register it with RegisterComponent", name)
+	}
+	return codec, nil
+}
+
+func lookupRelation(name string) (relationCodec, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	codec, ok := registry.relations[name]
+	if !ok {
+		return nil, fmt.Errorf("ecs: unknown relation type %q, register it with RegisterRelation", name)
+	}
+	return codec, nil
+}
+
+// typeCodec serializes components and resources of a registered type, independent of
+// the type.
+type typeCodec interface {
+	typeName() string
+	// component returns the component of the entity, or nil if it has none.
+	component(entity *Entity) any
+	// decodeComponent decodes a component, and returns a function adding it to an entity.
+	decodeComponent(data []byte) (func(entity *Entity), error)
+	// resource returns the resource of the scene, or nil if it has none.
+	resource(scene *Scene) any
+	// decodeResource decodes a resource, and returns a function setting it on a scene.
+	decodeResource(data []byte) (func(scene *Scene), error)
+
+	valueCodec
+	// components returns all components of the type in the scene as a []T, with the
+	// indices of their entities.
+	components(scene *Scene) (indices []uint32, values any)
+	// addComponents adds the components in values, a []T, to the entities with the indices.
+	addComponents(scene *Scene, indices []uint32, values any)
+	addComponent(entity *Entity, value any)
+	removeComponent(entity *Entity)
+	// resourceValues returns the resource of the scene as a []T with one element, or nil
+	// if the scene has no resource of the type.
+	resourceValues(scene *Scene) any
+	setResource(scene *Scene, value any)
+	removeResource(scene *Scene)
+}
+
+type componentCodec[T any] struct {
+	name string
+	valuesOf[T]
+}
+
+func (codec componentCodec[T]) typeName() string {
+	return codec.name
+}
+
+func (codec componentCodec[T]) component(entity *Entity) any {
+	componentPool, ok := findPool[T](entity.scene)
+	if !ok {
+		return nil
+	}
+	if component := componentPool.get(entity); component != nil {
+		return component
+	}
+	return nil
+}
+
+func (codec componentCodec[T]) decodeComponent(data []byte) (func(entity *Entity), error) {
+	component := new(T)
+	if err := json.Unmarshal(data, component); err != nil {
+		return nil, fmt.Errorf("ecs: decoding component %q: %w", codec.name, err)
+	}
+	return func(entity *Entity) {
+		AddComponent(entity, component)
+	}, nil
+}
+
+func (codec componentCodec[T]) resource(scene *Scene) any {
+	if res, ok := Resource[T](scene); ok {
+		return res
+	}
+	return nil
+}
+
+func (codec componentCodec[T]) decodeResource(data []byte) (func(scene *Scene), error) {
+	res := new(T)
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("ecs: decoding resource %q: %w", codec.name, err)
+	}
+	return func(scene *Scene) {
+		SetResource(scene, res)
+	}, nil
+}
+
+// relationCodec serializes relations of a registered type, independent of the type.
+type relationCodec interface {
+	typeName() string
+	// relations calls fn for every relation from the entity.
+	relations(entity *Entity, fn func(target EntityID, data any))
+	// decodeRelation decodes the data of a relation, and returns a function adding the
+	// relation between two entities.
+	decodeRelation(data []byte) (func(source, target *Entity), error)
+
+	valueCodec
+	// allRelations returns all relations of the type in the scene as a []R, with the
+	// index of the source and the target of each relation.
+	allRelations(scene *Scene) (indices []uint32, values any)
+	addRelation(source, target *Entity, value any)
+	removeRelation(source, target *Entity)
+}
+
+type relationCodecOf[R any] struct {
+	name string
+	valuesOf[R]
+}
+
+func (codec relationCodecOf[R]) typeName() string {
+	return codec.name
+}
+
+func (codec relationCodecOf[R]) relations(entity *Entity, fn func(target EntityID, data any)) {
+	relationPool, ok := findPool[relation[R]](entity.scene)
+	if !ok {
+		return
+	}
+	if rel := relationPool.get(entity); rel != nil {
+		for i := range rel.pairs {
+			fn(rel.pairs[i].target, &rel.pairs[i].data)
+		}
+	}
+}
+
+func (codec relationCodecOf[R]) decodeRelation(data []byte) (func(source, target *Entity), error) {
+	value := new(R)
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("ecs: decoding relation %q: %w", codec.name, err)
+	}
+	return func(source, target *Entity) {
+		AddRelation(source, target, value)
+	}, nil
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Cleanup decides what happens to relations when their target entity is removed.
+type Cleanup int
+
+const (
+	// CleanupRelation removes the relations to the removed target. This is the default.
+	CleanupRelation Cleanup = iota
+	// CleanupSource removes the source entities of the relations to the removed target.
+	CleanupSource
+)
+
+// relation is the component storing the relations of type R from an entity. Since it is
+// a normal component, relations are stored, queried and removed like other components.
+type relation[R any] struct {
+	pairs []relationPair[R]
+}
+
+func (relation[R]) internalComponent() {}
+
+type relationPair[R any] struct {
+	target EntityID
+	data   R
+}
+
+// relationKind keeps track of the relations of one type, independent of the type.
+type relationKind interface {
+	targetRemoved(target EntityID)
+}
+
+// relationIndex maps the targets of relations of type R to their sources, so that the
+// relations can be cleaned up when the target is removed.
+type relationIndex[R any] struct {
+	scene   *Scene
+	cleanup Cleanup
+	sources map[EntityID][]EntityID
+}
+
+func getRelationIndex[R any](scene *Scene) *relationIndex[R] {
+	relationType := reflect.TypeOf((*R)(nil))
+	if index, ok := scene.relations[relationType]; ok {
+		return index.(*relationIndex[R])
+	}
+	if scene.relations == nil {
+		scene.relations = make(map[reflect.Type]relationKind)
+	}
+	index := &relationIndex[R]{scene: scene, sources: make(map[EntityID][]EntityID)}
+	scene.relations[relationType] = index
+	scene.relationKinds = append(scene.relationKinds, index)
+
+	// The relations of removed sources are forgotten, whether the source or only the
+	// relation component is removed.
+	OnRemove(scene, func(entity Entity, component *relation[R]) {
+		for _, pair := range component.pairs {
+			index.removeSource(pair.target, entity.id)
+		}
+	})
+	return index
+}
+
+func (index *relationIndex[R]) addSource(target, source EntityID) {
+	index.sources[target] = append(index.sources[target], source)
+}
+
+func (index *relationIndex[R]) removeSource(target, source EntityID) {
+	sources := index.sources[target]
+	for i, id := range sources {
+		if id == source {
+			sources = append(sources[:i], sources[i+1:]...)
+			break
+		}
+	}
+	if len(sources) == 0 {
+		delete(index.sources, target)
+	} else {
+		index.sources[target] = sources
+	}
+}
+
+func (index *relationIndex[R]) targetRemoved(target EntityID) {
+	sources, ok := index.sources[target]
+	if !ok {
+		return
+	}
+	delete(index.sources, target)
+
+	scene := index.scene
+	relationPool := getPool[relation[R]](scene)
+	for _, id := range sources {
+		source, ok := scene.Entity(id)
+		if !ok {
+			continue
+		}
+		if index.cleanup == CleanupSource {
+			scene.removeEntity(&source)
+			continue
+		}
+		component := relationPool.component(&source)
+		if component == nil {
+			continue
+		}
+		component.component.removePair(target)
+		component.MarkChanged()
+		if len(component.component.pairs) == 0 {
+			relationPool.remove(&source)
+		}
+	}
+}
+
+func (rel *relation[R]) removePair(target EntityID) bool {
+	for i, pair := range rel.pairs {
+		if pair.target == target {
+			rel.pairs = append(rel.pairs[:i], rel.pairs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetRelationCleanup sets what happens to relations of type R when their target entity is
+// removed. The default is CleanupRelation.
+func SetRelationCleanup[R any](scene *Scene, cleanup Cleanup) {
+	getRelationIndex[R](scene).cleanup = cleanup
+}
+
+// AddRelation adds a relation of type R from the source to the target, with the data.
+// An entity can have relations of the same type to several targets. The data is
+// overwritten if the relation already exists. An error is returned if the entities are
+// deleted or belong to different scenes.
+func AddRelation[R any](source, target *Entity, data *R) error {
+	if !source.alive() {
+		return ErrEntityDead
+	}
+	if !target.alive() || target.scene != source.scene {
+		return errors.New("ecs: target not registered to the scene of the entity (or has been deleted)")
+	}
+	scene := source.scene
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+
+	index := getRelationIndex[R](scene)
+	relationPool := getPool[relation[R]](scene)
+	component := relationPool.component(source)
+	if component == nil {
+		relationPool.add(source, &relation[R]{pairs: []relationPair[R]{{target.id, *data}}})
+		index.addSource(target.id, source.id)
+		return nil
+	}
+
+	component.MarkChanged()
+	rel := &component.component
+	for i := range rel.pairs {
+		if rel.pairs[i].target == target.id {
+			rel.pairs[i].data = *data
+			return nil
+		}
+	}
+	rel.pairs = append(rel.pairs, relationPair[R]{target.id, *data})
+	index.addSource(target.id, source.id)
+	return nil
+}
+
+// RemoveRelation removes the relation of type R from the source to the target. An error
+// is returned if the relation does not exist or if the source is deleted.
+func RemoveRelation[R any](source, target *Entity) error {
+	if !source.alive() {
+		return ErrEntityDead
+	}
+	scene := source.scene
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+
+	relationPool := getPool[relation[R]](scene)
+	component := relationPool.component(source)
+	if component == nil || !component.component.removePair(target.id) {
+		return fmt.Errorf("ecs: no relation of type %s between the entities", reflect.TypeOf(new(R)))
+	}
+	getRelationIndex[R](scene).removeSource(target.id, source.id)
+	component.MarkChanged()
+	if len(component.component.pairs) == 0 {
+		relationPool.remove(source)
+	}
+	return nil
+}
+
+// GetRelation returns a pointer to the data of the relation of type R from the source to
+// the target. An error is returned if the relation does not exist or if the source is
+// deleted.
+func GetRelation[R any](source, target *Entity) (*R, error) {
+	if !source.alive() {
+		return nil, ErrEntityDead
+	}
+
+	if rel, ok := TryGet[relation[R]](source); ok {
+		for i := range rel.pairs {
+			if rel.pairs[i].target == target.id {
+				return &rel.pairs[i].data, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("ecs: no relation of type %s between the entities", reflect.TypeOf(new(R)))
+}
+
+// Targets returns the targets of the relations of type R from the source, in the order
+// the relations were added.
+func Targets[R any](source *Entity) []Entity {
+	if !source.alive() {
+		return nil
+	}
+	rel, ok := TryGet[relation[R]](source)
+	if !ok {
+		return nil
+	}
+	targets := make([]Entity, len(rel.pairs))
+	for i, pair := range rel.pairs {
+		targets[i] = Entity{pair.target, source.scene}
+	}
+	return targets
+}
+
+// Sources returns the sources of the relations of type R to the target.
+func Sources[R any](target *Entity) []Entity {
+	if !target.alive() {
+		return nil
+	}
+	index, ok := target.scene.relations[reflect.TypeOf((*R)(nil))]
+	if !ok {
+		return nil
+	}
+	ids := index.(*relationIndex[R]).sources[target.id]
+	sources := make([]Entity, len(ids))
+	for i, id := range ids {
+		sources[i] = Entity{id, target.scene}
+	}
+	return sources
+}
+
+// QueryRelation calls fn for every relation of type R, from sources matching all the
+// filters. Sources with several relations of type R are visited once per relation.
+func QueryRelation[R any](scene *Scene, fn func(source *Entity, target Entity, data *R), filters ...Filter) {
+	Query1(scene, func(entity *Entity, rel *relation[R]) {
+		for i := range rel.pairs {
+			fn(entity, Entity{rel.pairs[i].target, scene}, &rel.pairs[i].data)
+		}
+	}, filters...)
+}
+
+type relationFilter[R any] struct {
+	target *EntityID // nil matches any target
+}
+
+// HasRelation returns a filter only passing entities with a relation of type R to any
+// target.
+func HasRelation[R any]() Filter {
+	return relationFilter[R]{}
+}
+
+// RelatedTo returns a filter only passing entities with a relation of type R to the target.
+func RelatedTo[R any](target *Entity) Filter {
+	id := target.id
+	return relationFilter[R]{&id}
+}
+
+func (filter relationFilter[R]) matcher(scene *Scene) func(entity *Entity) bool {
+	relationPool, ok := findPool[relation[R]](scene)
+	if !ok {
+		return func(entity *Entity) bool { return false }
+	}
+	return func(entity *Entity) bool {
+		rel := relationPool.get(entity)
+		if rel == nil {
+			return false
+		}
+		if filter.target == nil {
+			return true
+		}
+		for _, pair := range rel.pairs {
+			if pair.target == *filter.target {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// DeferAddRelation records adding a relation of type R from the source to the target,
+// with a copy of the data.
+func DeferAddRelation[R any](buffer *CommandBuffer, source, target *Entity, data *R) {
+	resolveSource, resolveTarget := buffer.resolve(source), buffer.resolve(target)
+	copied := *data
+	buffer.record(func() {
+		AddRelation(resolveSource(), resolveTarget(), &copied)
+	})
+}
+
+// DeferRemoveRelation records removing the relation of type R from the source to the target.
+func DeferRemoveRelation[R any](buffer *CommandBuffer, source, target *Entity) {
+	resolveSource, resolveTarget := buffer.resolve(source), buffer.resolve(target)
+	buffer.record(func() {
+		RemoveRelation[R](resolveSource(), resolveTarget())
+	})
+}
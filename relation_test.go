@@ -0,0 +1,140 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type likes struct {
+	amount int
+}
+
+type inInventory struct{}
+
+func TestRelation(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		alice, bob, carol := scene.NewEntity(), scene.NewEntity(), scene.NewEntity()
+
+		ecs.AddRelation(&alice, &bob, &likes{amount: 1})
+		ecs.AddRelation(&alice, &carol, &likes{amount: 2})
+		ecs.AddRelation(&bob, &carol, &likes{amount: 3})
+		ecs.AddRelation(&alice, &bob, &likes{amount: 4})
+
+		data, err := ecs.GetRelation[likes](&alice, &bob)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(data.amount), subx.CompareEqual(4)))
+		_, err = ecs.GetRelation[likes](&bob, &alice)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Targets[likes](&alice)), subx.DeepEqual([]ecs.Entity{bob, carol})))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Sources[likes](&carol)), subx.DeepEqual([]ecs.Entity{alice, bob})))
+
+		total := 0
+		ecs.QueryRelation(scene, func(source *ecs.Entity, target ecs.Entity, data *likes) {
+			total += data.amount
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(total), subx.CompareEqual(9)))
+
+		var related []ecs.Entity
+		ecs.QueryRelation(scene, func(source *ecs.Entity, target ecs.Entity, data *likes) {
+			related = append(related, *source)
+		}, ecs.RelatedTo[likes](&bob))
+		t.Run("Expected correct result", subx.Test(subx.Value(related), subx.DeepEqual([]ecs.Entity{alice, alice})))
+
+		err = ecs.RemoveRelation[likes](&alice, &bob)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		err = ecs.RemoveRelation[likes](&alice, &bob)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Targets[likes](&alice)), subx.DeepEqual([]ecs.Entity{carol})))
+		t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.Sources[likes](&bob))), subx.CompareEqual(0)))
+	}
+}
+
+func TestRelationFilters(t *testing.T) {
+	scene := ecs.Scene{}
+	chest, bag := scene.NewEntity(), scene.NewEntity()
+	entities := make([]ecs.Entity, 4)
+	for n := range entities {
+		entities[n] = scene.NewEntity()
+		ecs.AddComponent(&entities[n], &health{hp: n})
+	}
+	ecs.AddRelation(&entities[0], &chest, &inInventory{})
+	ecs.AddRelation(&entities[1], &bag, &inInventory{})
+	ecs.AddRelation(&entities[2], &chest, &inInventory{})
+
+	var inChest, inAny []int
+	ecs.Query1(&scene, func(entity *ecs.Entity, h *health) {
+		inChest = append(inChest, h.hp)
+	}, ecs.RelatedTo[inInventory](&chest))
+	ecs.Query1(&scene, func(entity *ecs.Entity, h *health) {
+		inAny = append(inAny, h.hp)
+	}, ecs.HasRelation[inInventory]())
+
+	t.Run("Expected correct result", subx.Test(subx.Value(inChest), subx.DeepEqual([]int{0, 2})))
+	t.Run("Expected correct result", subx.Test(subx.Value(inAny), subx.DeepEqual([]int{0, 1, 2})))
+}
+
+func TestRelationCleanup(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		ecs.SetRelationCleanup[inInventory](scene, ecs.CleanupSource)
+
+		alice, bob, carol := scene.NewEntity(), scene.NewEntity(), scene.NewEntity()
+		ecs.AddRelation(&alice, &bob, &likes{})
+		ecs.AddRelation(&alice, &carol, &likes{})
+		bob.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(alice.ID())), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Targets[likes](&alice)), subx.DeepEqual([]ecs.Entity{carol})))
+		carol.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(len(ecs.Targets[likes](&alice))), subx.CompareEqual(0)))
+
+		chest, sword, shield := scene.NewEntity(), scene.NewEntity(), scene.NewEntity()
+		ecs.AddRelation(&sword, &chest, &inInventory{})
+		ecs.AddRelation(&shield, &chest, &inInventory{})
+		// Relations in a cycle are removed once.
+		ecs.AddRelation(&chest, &sword, &inInventory{})
+		chest.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(sword.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(shield.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(alice.ID())), subx.CompareEqual(true)))
+	}
+}
+
+func TestRelationCleanupDescendant(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		ecs.SetRelationCleanup[inInventory](scene, ecs.CleanupSource)
+
+		// Removing the parent removes the child, which removes the parent through the
+		// relation before the parent has been removed itself.
+		parent, child := scene.NewEntity(), scene.NewEntity()
+		child.SetParent(&parent)
+		ecs.AddRelation(&parent, &child, &inInventory{})
+		parent.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(parent.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(child.ID())), subx.CompareEqual(false)))
+
+		// The index of the parent is only freed once, so new entities get different indices.
+		a, b := scene.NewEntity(), scene.NewEntity()
+		t.Run("Expected correct result", subx.Test(subx.Value(a.ID().Index()), subx.CompareNotEqual(b.ID().Index())))
+		err := ecs.AddComponent(&a, &position{})
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import "reflect"
+
+// resource is a value stored once per scene, instead of per entity.
+type resource struct {
+	value   any // *T
+	added   uint32
+	changed uint32
+}
+
+// SetResource stores the resource of type T in the scene, and replaces the existing
+// resource of type T. The resource is marked as changed. Resources must not be set or
+// removed while systems are updated in parallel.
+func SetResource[T any](scene *Scene, value *T) {
+	resourceType := reflect.TypeOf((*T)(nil))
+	if scene.resources == nil {
+		scene.resources = make(map[reflect.Type]*resource)
+	}
+	tick := scene.currentTick()
+	if existing, ok := scene.resources[resourceType]; ok {
+		existing.value = value
+		existing.changed = tick
+		return
+	}
+	scene.resources[resourceType] = &resource{value: value, added: tick, changed: tick}
+}
+
+// Resource returns the resource of type T. False is returned if the scene has no
+// resource of type T.
+func Resource[T any](scene *Scene) (*T, bool) {
+	res, ok := scene.resources[reflect.TypeOf((*T)(nil))]
+	if !ok {
+		return nil, false
+	}
+	return res.value.(*T), true
+}
+
+// MutResource returns the resource of type T, and marks it as changed. False is returned
+// if the scene has no resource of type T.
+func MutResource[T any](scene *Scene) (*T, bool) {
+	res, ok := scene.resources[reflect.TypeOf((*T)(nil))]
+	if !ok {
+		return nil, false
+	}
+	res.changed = scene.currentTick()
+	return res.value.(*T), true
+}
+
+// RemoveResource removes the resource of type T from the scene. False is returned if
+// the scene has no resource of type T.
+func RemoveResource[T any](scene *Scene) bool {
+	resourceType := reflect.TypeOf((*T)(nil))
+	if _, ok := scene.resources[resourceType]; !ok {
+		return false
+	}
+	delete(scene.resources, resourceType)
+	return true
+}
+
+// ResourceExists returns a condition which is true if the scene has a resource of type T.
+func ResourceExists[T any]() Condition {
+	return func(system SystemInterface) bool {
+		_, ok := Resource[T](system.Scene())
+		return ok
+	}
+}
+
+// ResourceEquals returns a condition which is true if the scene has a resource of type
+// T equal to the value. It is useful for updating systems only in some game states.
+func ResourceEquals[T comparable](value T) Condition {
+	return func(system SystemInterface) bool {
+		res, ok := Resource[T](system.Scene())
+		return ok && *res == value
+	}
+}
+
+// ResourceAdded returns a condition which is true if the resource of type T has been
+// added after the last update of the system.
+func ResourceAdded[T any]() Condition {
+	return func(system SystemInterface) bool {
+		res, ok := system.Scene().resources[reflect.TypeOf((*T)(nil))]
+		return ok && res.added > system.base().lastRun
+	}
+}
+
+// ResourceChanged returns a condition which is true if the resource of type T has been
+// added or changed after the last update of the system. Resources are changed by
+// SetResource and MutResource.
+func ResourceChanged[T any]() Condition {
+	return func(system SystemInterface) bool {
+		res, ok := system.Scene().resources[reflect.TypeOf((*T)(nil))]
+		return ok && res.changed > system.base().lastRun
+	}
+}
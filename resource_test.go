@@ -0,0 +1,84 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type camera struct {
+	zoom float64
+}
+
+type gameState int
+
+const (
+	statePlaying gameState = iota
+	statePaused
+)
+
+func TestResource(t *testing.T) {
+	scene := ecs.Scene{}
+
+	_, ok := ecs.Resource[camera](&scene)
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+
+	cam := &camera{zoom: 2}
+	ecs.SetResource(&scene, cam)
+	result, ok := ecs.Resource[camera](&scene)
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(result), subx.CompareEqual(cam)))
+
+	ecs.SetResource(&scene, &camera{zoom: 3})
+	result, _ = ecs.Resource[camera](&scene)
+	t.Run("Expected correct result", subx.Test(subx.Value(result.zoom), subx.CompareEqual(3.0)))
+
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.RemoveResource[camera](&scene)), subx.CompareEqual(true)))
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.RemoveResource[camera](&scene)), subx.CompareEqual(false)))
+	_, ok = ecs.Resource[camera](&scene)
+	t.Run("Expected correct result", subx.Test(subx.Value(ok), subx.CompareEqual(false)))
+}
+
+func TestResourceConditions(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	scene.AddSystem(&orderSystem{name: "playing", order: &order}, ecs.RunIf(ecs.ResourceEquals(statePlaying)))
+	scene.AddSystem(&orderSystem{name: "changed", order: &order}, ecs.RunIf(ecs.ResourceChanged[camera]()))
+	scene.AddSystem(&orderSystem{name: "added", order: &order}, ecs.RunIf(ecs.ResourceAdded[camera]()))
+	scene.AddSystem(&orderSystem{name: "exists", order: &order}, ecs.RunIf(ecs.ResourceExists[camera]()))
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{})))
+
+	state := statePlaying
+	ecs.SetResource(&scene, &state)
+	ecs.SetResource(&scene, &camera{})
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"playing", "changed", "added", "exists"})))
+
+	order = order[:0]
+	state = statePaused
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"exists"})))
+
+	order = order[:0]
+	ecs.MutResource[camera](&scene)
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"changed", "exists"})))
+}
@@ -18,117 +18,536 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"sync"
+)
+
+// Storage selects how a scene stores its components.
+type Storage int
+
+const (
+	// PoolStorage stores the components of each type in a separate pool. Adding and
+	// removing components is cheap, and AllComponents is supported.
+	PoolStorage Storage = iota
+	// ArchetypeStorage stores entities with the same set of component types together in
+	// tables, which makes iterating several component types cheap. Adding and removing
+	// components moves the entity between tables. AllComponents is not supported.
+	ArchetypeStorage
 )
 
 // Scene contains all entities, components and systems.
+// The zero value is an empty scene using PoolStorage.
 type Scene struct {
-	entityCounter uint32
+	entities     []entityRecord
+	freeEntities []uint32
 
 	componentPools     []poolInterface
 	componentIDs       map[reflect.Type]uint32
+	typeIDs            []uint32 // type index -> component ID + 1, 0 when not used
 	currentComponentID uint32
+	archetypes         *archetypeStorage // nil when using PoolStorage
+	resources          map[reflect.Type]*resource
+	relations          map[reflect.Type]relationKind
+	relationKinds      []relationKind  // in the order they were registered
+	tagPools           []poolInterface // pools of zero-size types, which are not in archetypes
+
+	systems        []*systemEntry               // in the order they were added
+	order          []*systemEntry               // in the order they are updated
+	batches        [stageCount][][]*systemEntry // systems in each stage which can be updated in parallel
+	disabledGroups map[string]bool
+	initialized    bool // true between Init and Delete
+	workers        int
+	chunkSize      int
+	parallel       bool // true while systems or ParallelEach are running in parallel
+
+	fixedTimestep float64
+	maxSubsteps   int
+	accumulator   float64
+	alpha         float64
+
+	commands     *CommandBuffer
+	commandsOnce sync.Once
+	tick         uint32
+	frame        uint64 // number of calls to Update
+}
+
+type entityRecord struct {
+	generation uint32
+	alive      bool
 
-	systems []SystemInterface
+	// location of the components when using ArchetypeStorage
+	archetype *archetype
+	row       uint32
+
+	parent   EntityID // 0 when the entity has no parent
+	children []EntityID
+
+	// signature has the bits of the IDs of the component types of the entity set.
+	signature signature
+}
+
+// NewScene creates an empty scene storing its components with the storage.
+func NewScene(storage Storage) *Scene {
+	scene := &Scene{}
+	if storage == ArchetypeStorage {
+		scene.archetypes = newArchetypeStorage()
+	}
+	return scene
 }
 
-// NewEntity creates a new entity, and returns it.
+// NewEntity creates a new entity, and returns it. Indices of removed entities are
+// reused, with a new generation.
 func (scene *Scene) NewEntity() Entity {
-	scene.entityCounter++
-	return Entity{scene.entityCounter, scene}
+	if scene.parallel {
+		panic("ecs: NewEntity called while systems are updated in parallel, use a CommandBuffer")
+	}
+
+	var index uint32
+	if length := len(scene.freeEntities); length > 0 {
+		index = scene.freeEntities[length-1]
+		scene.freeEntities = scene.freeEntities[:length-1]
+	} else {
+		index = uint32(len(scene.entities))
+		scene.entities = append(scene.entities, entityRecord{})
+	}
+
+	record := &scene.entities[index]
+	record.generation++
+	if record.generation == 0 {
+		record.generation++
+	}
+	record.alive = true
+	return Entity{newEntityID(index, record.generation), scene}
+}
+
+// Alive returns true if the ID belongs to an entity in the scene which has not been removed.
+func (scene *Scene) Alive(id EntityID) bool {
+	index := id.Index()
+	if index >= uint32(len(scene.entities)) {
+		return false
+	}
+	record := scene.entities[index]
+	return record.alive && record.generation == id.Generation()
+}
+
+// Entity returns the entity with the ID. False is returned if the entity has been removed.
+func (scene *Scene) Entity(id EntityID) (Entity, bool) {
+	if !scene.Alive(id) {
+		return Entity{}, false
+	}
+	return Entity{id, scene}, true
 }
 
-// AddSystem adds the system to the scene.
-func (scene *Scene) AddSystem(system SystemInterface) {
+// AddSystem adds the system to the scene. Systems are updated in the order they are
+// added, unless they are ordered with the Before and After options. An error is returned,
+// and the system is not added, if the system is already added or if the options make the
+// order contain a cycle. Init is called on the system if Scene.Init has already been
+// called.
+func (scene *Scene) AddSystem(system SystemInterface, options ...SystemOption) error {
+	if scene.parallel {
+		return errors.New("ecs: systems can not be added while systems are updated in parallel")
+	}
+	if scene.systemEntry(system) != nil {
+		return fmt.Errorf("ecs: system %T already added to the scene", system)
+	}
+	entry := &systemEntry{system: system, scene: scene, stage: StageUpdate}
+	for _, option := range options {
+		option(entry)
+	}
+	if entry.stage < 0 || entry.stage >= stageCount {
+		return fmt.Errorf("ecs: invalid stage %s", entry.stage)
+	}
+
+	systems := append(scene.systems[:len(scene.systems):len(scene.systems)], entry)
+	order, batches, err := scheduleSystems(systems)
+	if err != nil {
+		return err
+	}
+
 	system.setScene(scene)
-	scene.systems = append(scene.systems, system)
+	scene.systems = systems
+	scene.trackRemovals()
+	scene.order = order
+	scene.batches = batches
+
+	if initSystem, ok := system.(InitListener); ok && scene.initialized {
+		initSystem.Init()
+	}
+	return nil
+}
+
+// RemoveSystem removes the system from the scene. Delete is called on the system if
+// Scene.Init has been called, and Scene.Delete has not. An error is returned if the
+// system is not added to the scene.
+func (scene *Scene) RemoveSystem(system SystemInterface) error {
+	if scene.parallel {
+		return errors.New("ecs: systems can not be removed while systems are updated in parallel")
+	}
+	entry := scene.systemEntry(system)
+	if entry == nil {
+		return fmt.Errorf("ecs: system %T not added to the scene", system)
+	}
+
+	systems := make([]*systemEntry, 0, len(scene.systems)-1)
+	for _, other := range scene.systems {
+		if other != entry {
+			systems = append(systems, other)
+		}
+	}
+	// Removing a system can not make the order contain a cycle.
+	order, batches, _ := scheduleSystems(systems)
+	scene.systems = systems
+	scene.order = order
+	scene.batches = batches
+	entry.removed = true
+
+	if deleteSystem, ok := system.(DeleteListener); ok && scene.initialized {
+		deleteSystem.Delete()
+	}
+	return nil
+}
+
+// SetSystemEnabled enables or disables the system. Disabled systems stay in the scene,
+// but are not updated. When the system is enabled again, change detection reports the
+// changes since it was last updated. An error is returned if the system is not added to
+// the scene.
+func (scene *Scene) SetSystemEnabled(system SystemInterface, enabled bool) error {
+	entry := scene.systemEntry(system)
+	if entry == nil {
+		return fmt.Errorf("ecs: system %T not added to the scene", system)
+	}
+	entry.disabled = !enabled
+	return nil
 }
 
-// Init calls Init functions on all systems.
+// SetGroupEnabled enables or disables all systems in the group. A system is only updated
+// if it is enabled and none of its groups are disabled.
+func (scene *Scene) SetGroupEnabled(group string, enabled bool) {
+	if scene.disabledGroups == nil {
+		scene.disabledGroups = make(map[string]bool)
+	}
+	if enabled {
+		delete(scene.disabledGroups, group)
+	} else {
+		scene.disabledGroups[group] = true
+	}
+}
+
+func (scene *Scene) systemEntry(system SystemInterface) *systemEntry {
+	for _, entry := range scene.systems {
+		if entry.system == system {
+			return entry
+		}
+	}
+	return nil
+}
+
+// SetWorkers sets the maximum number of goroutines used for updating systems in parallel
+// and for ParallelEach. The default is runtime.GOMAXPROCS. Nothing is run in parallel
+// if n is 1.
+func (scene *Scene) SetWorkers(n int) {
+	scene.workers = n
+}
+
+func (scene *Scene) workerCount() int {
+	if scene.workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return scene.workers
+}
+
+// Init calls Init functions on all systems. Systems added after Init are initialized
+// when they are added.
 func (scene *Scene) Init() {
-	for _, system := range scene.systems {
-		if initSystem, ok := system.(InitListener); ok {
+	scene.initialized = true
+	for _, entry := range scene.order {
+		if initSystem, ok := entry.system.(InitListener); ok {
 			initSystem.Init()
 		}
 	}
 }
 
-// Update calls Update functions on all systems.
+// Update calls Update functions on all systems, stage by stage. Systems in
+// StageFixedUpdate are updated once for every fixed timestep passed, while systems in the
+// other stages are updated once with dt. The command buffers of the system and the scene
+// are flushed after each system is updated. Systems with run conditions are only updated
+// when all their conditions are true. Systems which have declared the components
+// they access with Reads and Writes are updated in parallel, when they do not conflict.
 func (scene *Scene) Update(dt float64) {
-	for _, system := range scene.systems {
-		system.Update(dt)
+	workers := scene.workerCount()
+	scene.updateStage(StagePreUpdate, dt, workers)
+	scene.updateFixed(dt, workers)
+	scene.updateStage(StageUpdate, dt, workers)
+	scene.updateStage(StagePostUpdate, dt, workers)
+	scene.updateStage(StageRender, dt, workers)
+	scene.tick++
+	scene.frame++
+	scene.pruneRemovals()
+}
+
+func (scene *Scene) updateStage(stage Stage, dt float64, workers int) {
+	for _, batch := range scene.batches[stage] {
+		if len(batch) > 1 && workers > 1 {
+			scene.updateParallel(activeSystems(batch), dt, workers)
+			continue
+		}
+		for _, entry := range batch {
+			// Systems may be removed or disabled by the systems updated before them.
+			if !entry.active() {
+				continue
+			}
+			scene.tick++
+			entry.system.Update(dt)
+			scene.updated(entry)
+		}
+	}
+}
+
+// updateParallel updates the systems in the batch on at most workers goroutines.
+func (scene *Scene) updateParallel(batch []*systemEntry, dt float64, workers int) {
+	if len(batch) == 0 {
+		return
+	}
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+	systems := make(chan SystemInterface)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for system := range systems {
+				system.Update(dt)
+			}
+		}()
+	}
+
+	scene.tick++
+	scene.parallel = true
+	for _, entry := range batch {
+		systems <- entry.system
+	}
+	close(systems)
+	wg.Wait()
+	scene.parallel = false
+
+	// The systems in the batch were updated at the same tick, so they can not tell the
+	// changes flushed by each other from their own changes if those are flushed at the
+	// same tick. The tick is therefore increased before each command buffer is flushed,
+	// so that all flushed changes are newer than the last run of the systems in the
+	// batch, which did not see them while they were updated.
+	lastRun := scene.currentTick()
+	for _, entry := range batch {
+		entry.system.base().lastRun = lastRun
+	}
+	for _, entry := range batch {
+		scene.tick++
+		entry.system.base().commands.Flush()
+		scene.Flush()
+	}
+}
+
+func activeSystems(batch []*systemEntry) []*systemEntry {
+	active := make([]*systemEntry, 0, len(batch))
+	for _, entry := range batch {
+		if entry.active() {
+			active = append(active, entry)
+		}
 	}
+	return active
 }
 
-// Delete calls Delete functions on all systems.
+// updated flushes the command buffers after the system has been updated.
+func (scene *Scene) updated(entry *systemEntry) {
+	system := entry.system.base()
+	system.commands.Flush()
+	scene.Flush()
+	system.lastRun = scene.currentTick()
+}
+
+// pruneRemovals forgets removals which all systems have been updated after.
+func (scene *Scene) pruneRemovals() {
+	oldest := scene.currentTick()
+	for _, entry := range scene.systems {
+		if lastRun := entry.system.base().lastRun; lastRun < oldest {
+			oldest = lastRun
+		}
+	}
+	for _, pool := range scene.componentPools {
+		pool.removals().prune(oldest)
+	}
+}
+
+// Commands returns the command buffer of the scene.
+func (scene *Scene) Commands() *CommandBuffer {
+	scene.commandsOnce.Do(func() {
+		scene.commands = NewCommandBuffer(scene)
+	})
+	return scene.commands
+}
+
+// Flush applies the commands recorded in the command buffer of the scene.
+func (scene *Scene) Flush() {
+	scene.Commands().Flush()
+}
+
+// Delete calls Delete functions on all systems. Systems removed between Init and Delete
+// are deleted when they are removed.
 func (scene *Scene) Delete() {
-	for _, system := range scene.systems {
-		if deleteSystem, ok := system.(DeleteListener); ok {
+	scene.initialized = false
+	for _, entry := range scene.order {
+		if deleteSystem, ok := entry.system.(DeleteListener); ok {
 			deleteSystem.Delete()
 		}
 	}
 }
 
+// removeEntity removes the entity, its components and its descendants, and cleans up
+// the relations to it.
 func (scene *Scene) removeEntity(entity *Entity) {
-	entity.id = 0
-	entity.scene = nil
-	for _, pool := range scene.componentPools {
-		pool.remove(entity)
+	// The entity may point into a pool, so it is copied before the pools are modified.
+	removed := *entity
+	scene.removeDescendants(removed.id.Index())
+	if !scene.Alive(removed.id) {
+		// The entity was removed with a descendant, by a relation to the descendant
+		// which removes its source.
+		return
+	}
+	if scene.archetypes != nil {
+		// The tags are removed first, since removing the entity from its archetype
+		// clears the signature, where the tags are stored.
+		for _, pool := range scene.tagPools {
+			pool.remove(&removed)
+		}
+		scene.archetypes.removeEntity(scene, removed)
+	} else {
+		for _, pool := range scene.componentPools {
+			pool.remove(&removed)
+		}
 	}
+	index := removed.id.Index()
+	scene.entities[index].alive = false
+	scene.freeEntities = append(scene.freeEntities, index)
+
+	// The relations are cleaned up after the entity is removed, so that relations in a
+	// cycle do not remove the entity again.
+	for _, kind := range scene.relationKinds {
+		kind.targetRemoved(removed.id)
+	}
+}
+
+// structuralError returns an error if entities and components can not be added or removed
+// now, because systems are updated in parallel.
+func (scene *Scene) structuralError() error {
+	if scene.parallel {
+		return errors.New("ecs: components and entities can not be added or removed while systems are updated in parallel, use a CommandBuffer")
+	}
+	return nil
 }
 
 // AllComponents returns a slice of all components of type T.
+// It panics if the scene uses ArchetypeStorage, where the components are spread over
+// several tables. Use queries instead.
 func AllComponents[T any](scene *Scene) []Component[T] {
-	id := getComponentID[T](scene)
-	componentPool := scene.componentPools[id].(*pool[T])
-	return componentPool.components
+	if scene.archetypes != nil {
+		panic("ecs: AllComponents is not supported by ArchetypeStorage")
+	}
+	componentPool, ok := findPool[T](scene)
+	if !ok {
+		return nil
+	}
+	return componentPool.chunks(nil)[0]
 }
 
 // AddComponent adds a new component to the entity, and overwrites if component of this
-// type is already added. An error is returned if the entity is deleted.
+// type is already added. ErrEntityDead is returned if the entity is deleted.
 func AddComponent[T any](entity *Entity, component *T) error {
-	if entity.scene == nil || entity.id == 0 {
-		return errors.New("ecs: entity not registered to a scene (or has been deleted)")
+	if !entity.alive() {
+		return ErrEntityDead
+	}
+	if err := entity.scene.structuralError(); err != nil {
+		return err
 	}
 
-	id := getComponentID[T](entity.scene)
-	componentPool := entity.scene.componentPools[id].(*pool[T])
-	componentPool.add(entity, component)
+	getPool[T](entity.scene).add(entity, component)
 
 	return nil
 }
 
 // GetComponent returns a pointer to the component of type T from the entity.
-// An error is returned if the component does not exist or if the entity is
-// deleted.
+// A *ComponentError is returned if the component does not exist, and ErrEntityDead if
+// the entity is deleted.
 func GetComponent[T any](entity *Entity) (*T, error) {
-	if entity.scene == nil || entity.id == 0 {
-		return nil, errors.New("ecs: entity not registered to a scene (or has been deleted)")
+	if !entity.alive() {
+		return nil, ErrEntityDead
 	}
 
-	id := getComponentID[T](entity.scene)
-	componentPool := entity.scene.componentPools[id].(*pool[T])
-
+	componentPool, ok := findPool[T](entity.scene)
+	if !ok {
+		return nil, newComponentError[T](entity)
+	}
 	result := componentPool.get(entity)
 	if result == nil {
-		return nil, fmt.Errorf("ecs: no component of type %s added to entity", reflect.TypeOf(new(T)))
+		return nil, newComponentError[T](entity)
 	}
 	return result, nil
 }
 
+// TryGet returns a pointer to the component of type T from the entity, and true, or nil
+// and false if the component does not exist or the entity is deleted. Unlike
+// GetComponent, it does not allocate an error when the component is missing.
+func TryGet[T any](entity *Entity) (*T, bool) {
+	if !entity.alive() {
+		return nil, false
+	}
+	componentPool, ok := findPool[T](entity.scene)
+	if !ok {
+		return nil, false
+	}
+	result := componentPool.get(entity)
+	return result, result != nil
+}
+
 // RemoveComponent removes the component of type T from the entity.
-// An error is returned if the component does not exist or if the
-// entity is deleted.
+// A *ComponentError is returned if the component does not exist, and ErrEntityDead if
+// the entity is deleted.
 func RemoveComponent[T any](entity *Entity) error {
-	if entity.scene == nil || entity.id == 0 {
-		return errors.New("ecs: entity not registered to a scene (or has been deleted)")
+	if !entity.alive() {
+		return ErrEntityDead
+	}
+	if err := entity.scene.structuralError(); err != nil {
+		return err
 	}
 	id := getComponentID[T](entity.scene)
 	if !entity.scene.componentPools[id].remove(entity) {
-		return fmt.Errorf("ecs: no component of type %s added to entity", reflect.TypeOf(new(T)))
+		return newComponentError[T](entity)
 	}
 	return nil
 }
 
+// findPool returns the pool of components of type T, without creating it if no
+// components of type T have been used in the scene. Code which only reads components
+// uses findPool, and treats a missing pool as empty, since creating pools is not safe
+// while systems are updated in parallel.
+func findPool[T any](scene *Scene) (typedPool[T], bool) {
+	id, ok := findComponentID[T](scene)
+	if !ok {
+		return nil, false
+	}
+	return scene.componentPools[id].(typedPool[T]), true
+}
+
+// findComponentID returns the ID of the component type T, without registering the type.
+func findComponentID[T any](scene *Scene) (uint32, bool) {
+	id, ok := scene.componentIDs[reflect.TypeOf((*T)(nil))]
+	return id, ok
+}
+
+func getPool[T any](scene *Scene) typedPool[T] {
+	return scene.componentPools[getComponentID[T](scene)].(typedPool[T])
+}
+
 func getComponentID[T any](scene *Scene) uint32 {
 	componentType := reflect.TypeOf((*T)(nil))
 	if scene.componentIDs == nil {
@@ -138,7 +557,21 @@ func getComponentID[T any](scene *Scene) uint32 {
 	if !ok {
 		id = scene.currentComponentID
 		scene.componentIDs[componentType] = id
-		scene.componentPools = append(scene.componentPools, &pool[T]{nil, make(map[uint32]uint32)}) //make([]Component[T], 0, 1)
+		index := typeIndex(componentType)
+		for index >= uint32(len(scene.typeIDs)) {
+			scene.typeIDs = append(scene.typeIDs, 0)
+		}
+		scene.typeIDs[index] = id + 1
+		if isTag[T]() {
+			tags := &tagPool[T]{id: id, scene: scene}
+			scene.componentPools = append(scene.componentPools, tags)
+			scene.tagPools = append(scene.tagPools, tags)
+		} else if scene.archetypes != nil {
+			scene.componentPools = append(scene.componentPools, &archetypePool[T]{id: id, scene: scene})
+		} else {
+			scene.componentPools = append(scene.componentPools, newPool[T](id))
+		}
+		scene.componentPools[id].removals().tracked = len(scene.systems) > 0
 		scene.currentComponentID++
 	}
 	return id
@@ -0,0 +1,252 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// systemEntry is a system added to a scene, with the options it was added with.
+type systemEntry struct {
+	system     SystemInterface
+	scene      *Scene
+	stage      Stage
+	labels     []string
+	before     []string
+	after      []string
+	groups     []string
+	conditions []Condition
+	reads      []uint32 // component IDs
+	writes     []uint32 // component IDs
+
+	disabled bool
+	removed  bool // set when removed while the scene is updated
+}
+
+// SystemOption configures a system added with Scene.AddSystem.
+type SystemOption func(entry *systemEntry)
+
+// Label labels the system, so that other systems can be ordered before or after it.
+// Several systems can have the same label.
+func Label(labels ...string) SystemOption {
+	return func(entry *systemEntry) {
+		entry.labels = append(entry.labels, labels...)
+	}
+}
+
+// Before orders the system before all systems with the label.
+func Before(label string) SystemOption {
+	return func(entry *systemEntry) {
+		entry.before = append(entry.before, label)
+	}
+}
+
+// After orders the system after all systems with the label.
+func After(label string) SystemOption {
+	return func(entry *systemEntry) {
+		entry.after = append(entry.after, label)
+	}
+}
+
+// InGroup adds the system to the groups, so that all systems in a group can be enabled
+// and disabled together with Scene.SetGroupEnabled.
+func InGroup(groups ...string) SystemOption {
+	return func(entry *systemEntry) {
+		entry.groups = append(entry.groups, groups...)
+	}
+}
+
+// Reads declares that the system reads components of type T. Systems declaring all the
+// component types they access can be updated in parallel with other systems, when they
+// do not write components the other systems access. Systems without declarations are
+// never updated in parallel with other systems.
+//
+// Systems updated in parallel must not add or remove components and entities directly,
+// but use their command buffer.
+func Reads[T any]() SystemOption {
+	return func(entry *systemEntry) {
+		entry.reads = append(entry.reads, getComponentID[T](entry.scene))
+	}
+}
+
+// Writes declares that the system reads and writes components of type T. See Reads.
+func Writes[T any]() SystemOption {
+	return func(entry *systemEntry) {
+		entry.writes = append(entry.writes, getComponentID[T](entry.scene))
+	}
+}
+
+// SystemOrder returns the systems of the scene in the order they are updated, stage by stage.
+func (scene *Scene) SystemOrder() []SystemInterface {
+	systems := make([]SystemInterface, len(scene.order))
+	for i, entry := range scene.order {
+		systems[i] = entry.system
+	}
+	return systems
+}
+
+// PrintSystemOrder writes the systems of the scene in the order they are updated to w,
+// one system per line with its stage.
+func (scene *Scene) PrintSystemOrder(w io.Writer) error {
+	for i, entry := range scene.order {
+		if _, err := fmt.Fprintf(w, "%d: %s %s\n", i+1, entry.stage, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (entry *systemEntry) String() string {
+	if len(entry.labels) == 0 {
+		return fmt.Sprintf("%T", entry.system)
+	}
+	return fmt.Sprintf("%T [%s]", entry.system, strings.Join(entry.labels, ", "))
+}
+
+// active returns true if the system should be updated now.
+func (entry *systemEntry) active() bool {
+	if entry.disabled || entry.removed {
+		return false
+	}
+	for _, group := range entry.groups {
+		if entry.scene.disabledGroups[group] {
+			return false
+		}
+	}
+	for _, condition := range entry.conditions {
+		if !condition(entry.system) {
+			return false
+		}
+	}
+	return true
+}
+
+func (entry *systemEntry) hasLabel(label string) bool {
+	for _, l := range entry.labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// runsBefore returns true if entry has to be updated before other.
+func (entry *systemEntry) runsBefore(other *systemEntry) bool {
+	for _, label := range entry.before {
+		if other.hasLabel(label) {
+			return true
+		}
+	}
+	for _, label := range other.after {
+		if entry.hasLabel(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortSystems orders the systems topologically by their ordering constraints. Systems
+// without constraints between them keep the order they were added in. An error is
+// returned if the constraints contain a cycle.
+func sortSystems(systems []*systemEntry) ([]*systemEntry, error) {
+	// dependencies[i] is the number of unordered systems which system i runs after.
+	dependencies := make([]int, len(systems))
+	for i, entry := range systems {
+		for j, other := range systems {
+			if i != j && other.runsBefore(entry) {
+				dependencies[i]++
+			}
+		}
+	}
+
+	order := make([]*systemEntry, 0, len(systems))
+	ordered := make([]bool, len(systems))
+	for len(order) < len(systems) {
+		next := -1
+		for i := range systems {
+			if !ordered[i] && dependencies[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			var cycle []string
+			for i, entry := range systems {
+				if !ordered[i] {
+					cycle = append(cycle, entry.String())
+				}
+			}
+			return nil, fmt.Errorf("ecs: cycle in system order between %s", strings.Join(cycle, ", "))
+		}
+
+		ordered[next] = true
+		order = append(order, systems[next])
+		for i, entry := range systems {
+			if !ordered[i] && systems[next].runsBefore(entry) {
+				dependencies[i]--
+			}
+		}
+	}
+	return order, nil
+}
+
+// exclusive returns true if the system has not declared which components it accesses.
+func (entry *systemEntry) exclusive() bool {
+	return len(entry.reads) == 0 && len(entry.writes) == 0
+}
+
+// conflicts returns true if the systems can not be updated in parallel.
+func (entry *systemEntry) conflicts(other *systemEntry) bool {
+	if entry.exclusive() || other.exclusive() || entry.runsBefore(other) || other.runsBefore(entry) {
+		return true
+	}
+	return writesAny(entry.writes, other.reads, other.writes) || writesAny(other.writes, entry.reads, entry.writes)
+}
+
+func writesAny(writes []uint32, accessed ...[]uint32) bool {
+	for _, id := range writes {
+		for _, ids := range accessed {
+			for _, other := range ids {
+				if id == other {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// batchSystems splits the ordered systems into batches of consecutive systems which
+// can be updated in parallel.
+func batchSystems(order []*systemEntry) [][]*systemEntry {
+	var batches [][]*systemEntry
+	var batch []*systemEntry
+	for _, entry := range order {
+		for _, other := range batch {
+			if entry.conflicts(other) {
+				batches = append(batches, batch)
+				batch = nil
+				break
+			}
+		}
+		batch = append(batch, entry)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"reflect"
+	"sync"
+)
+
+// signature is a bitset of the IDs of the component types of an entity, so that checking
+// for several components is a few AND operations instead of a lookup in every pool.
+type signature []uint64
+
+func (s *signature) set(id uint32) {
+	*s = s.with(id)
+}
+
+// with returns the signature with the bit id set, which uses the memory of s if it has
+// room for the bit.
+func (s signature) with(id uint32) signature {
+	word := id / 64
+	for word >= uint32(len(s)) {
+		s = append(s, 0)
+	}
+	s[word] |= 1 << (id % 64)
+	return s
+}
+
+func (s signature) clear(id uint32) {
+	if word := id / 64; word < uint32(len(s)) {
+		s[word] &^= 1 << (id % 64)
+	}
+}
+
+func (s signature) has(id uint32) bool {
+	word := id / 64
+	return word < uint32(len(s)) && s[word]&(1<<(id%64)) != 0
+}
+
+// contains returns true if all the bits in other are set in s.
+func (s signature) contains(other signature) bool {
+	if len(other) > len(s) {
+		for _, word := range other[len(s):] {
+			if word != 0 {
+				return false
+			}
+		}
+		other = other[:len(s)]
+	}
+	for i, word := range other {
+		if s[i]&word != word {
+			return false
+		}
+	}
+	return true
+}
+
+// reset clears all bits, keeping the memory for the next entity with the index.
+func (s signature) reset() {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+// ComponentType identifies a component type in HasAll. Create the component types once
+// with TypeOf, and reuse them.
+type ComponentType struct {
+	componentType reflect.Type
+	index         uint32 // index of the type in the scenes, 0 for the zero value
+}
+
+// typeIndices gives every component type an index, which is the same in all scenes, so
+// that HasAll finds the IDs of the types in a slice instead of a map.
+var typeIndices struct {
+	sync.Mutex
+	byType map[reflect.Type]uint32
+}
+
+func typeIndex(componentType reflect.Type) uint32 {
+	typeIndices.Lock()
+	defer typeIndices.Unlock()
+	if typeIndices.byType == nil {
+		typeIndices.byType = make(map[reflect.Type]uint32)
+	}
+	index, ok := typeIndices.byType[componentType]
+	if !ok {
+		index = uint32(len(typeIndices.byType)) + 1
+		typeIndices.byType[componentType] = index
+	}
+	return index
+}
+
+// TypeOf returns the component type of T.
+func TypeOf[T any]() ComponentType {
+	componentType := reflect.TypeOf((*T)(nil))
+	return ComponentType{componentType, typeIndex(componentType)}
+}
+
+// Has returns true if the entity has a component of type T. It returns false if the entity
+// is deleted.
+func Has[T any](entity *Entity) bool {
+	if !entity.alive() {
+		return false
+	}
+	id, ok := findComponentID[T](entity.scene)
+	return ok && entity.scene.entities[entity.id.Index()].signature.has(id)
+}
+
+// HasAll returns true if the entity has components of all the types. It returns false if
+// the entity is deleted.
+//
+//	if ecs.HasAll(&entity, ecs.TypeOf[position](), ecs.TypeOf[velocity]()) {
+//	    // Move the entity
+//	}
+func HasAll(entity *Entity, types ...ComponentType) bool {
+	if !entity.alive() {
+		return false
+	}
+	// The required signature fits in the array for the first 256 component types, so it
+	// is usually not allocated.
+	var words [4]uint64
+	required := signature(words[:0])
+	typeIDs := entity.scene.typeIDs
+	for _, t := range types {
+		if t.index >= uint32(len(typeIDs)) || typeIDs[t.index] == 0 {
+			return false
+		}
+		required = required.with(typeIDs[t.index] - 1)
+	}
+	return entity.scene.entities[entity.id.Index()].signature.contains(required)
+}
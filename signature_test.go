@@ -0,0 +1,146 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+func TestHas(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		ecs.AddComponent(&entity, &velocity{})
+
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[position](&entity)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[health](&entity)), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasAll(&entity, ecs.TypeOf[position](), ecs.TypeOf[velocity]())), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasAll(&entity, ecs.TypeOf[position](), ecs.TypeOf[health]())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasAll(&entity)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasAll(&entity, ecs.ComponentType{})), subx.CompareEqual(false)))
+
+		ecs.RemoveComponent[velocity](&entity)
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[velocity](&entity)), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[position](&entity)), subx.CompareEqual(true)))
+
+		// The signature is cleared when the entity is removed, and not given to the next
+		// entity with the index.
+		entity.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[position](&entity)), subx.CompareEqual(false)))
+		next := scene.NewEntity()
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[position](&next)), subx.CompareEqual(false)))
+	}
+}
+
+// pair gives a component type for each combination of A and B.
+type pair[A, B any] struct {
+	a A
+	b B
+}
+
+// addPairs adds components of 9 types to the entity.
+func addPairs[A any](entity *ecs.Entity) {
+	ecs.AddComponent(entity, &pair[A, int]{})
+	ecs.AddComponent(entity, &pair[A, int8]{})
+	ecs.AddComponent(entity, &pair[A, int16]{})
+	ecs.AddComponent(entity, &pair[A, int32]{})
+	ecs.AddComponent(entity, &pair[A, int64]{})
+	ecs.AddComponent(entity, &pair[A, uint]{})
+	ecs.AddComponent(entity, &pair[A, uint8]{})
+	ecs.AddComponent(entity, &pair[A, uint16]{})
+	ecs.AddComponent(entity, &pair[A, uint32]{})
+}
+
+func TestHasManyTypes(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		// The signatures grow beyond one word with more than 64 component types.
+		other := scene.NewEntity()
+		addPairs[int](&other)
+		addPairs[int8](&other)
+		addPairs[int16](&other)
+		addPairs[int32](&other)
+		addPairs[int64](&other)
+		addPairs[uint](&other)
+		addPairs[uint8](&other)
+		addPairs[uint16](&other)
+
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &position{})
+		addPairs[uint32](&entity)
+		both := ecs.HasAll(&entity, ecs.TypeOf[position](), ecs.TypeOf[pair[uint32, uint32]]())
+		t.Run("Expected correct result", subx.Test(subx.Value(both), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[pair[int, int]](&entity)), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[pair[uint32, uint32]](&other)), subx.CompareEqual(false)))
+
+		var visited []ecs.EntityID
+		ecs.Query2(scene, func(entity *ecs.Entity, p *position, last *pair[uint32, uint32]) {
+			visited = append(visited, entity.ID())
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(visited), subx.DeepEqual([]ecs.EntityID{entity.ID()})))
+		visited = nil
+		ecs.Query1(scene, func(entity *ecs.Entity, first *pair[int, int]) {
+			visited = append(visited, entity.ID())
+		}, ecs.Without[position]())
+		t.Run("Expected correct result", subx.Test(subx.Value(visited), subx.DeepEqual([]ecs.EntityID{other.ID()})))
+	}
+}
+
+// newHasScene creates a scene with an entity with position, velocity and health.
+func newHasScene() (*ecs.Scene, ecs.Entity) {
+	scene := &ecs.Scene{}
+	entity := scene.NewEntity()
+	ecs.AddComponent(&entity, &position{})
+	ecs.AddComponent(&entity, &velocity{})
+	ecs.AddComponent(&entity, &health{})
+	return scene, entity
+}
+
+func BenchmarkHas(b *testing.B) {
+	_, entity := newHasScene()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ecs.Has[velocity](&entity)
+	}
+}
+
+func BenchmarkHasAll(b *testing.B) {
+	_, entity := newHasScene()
+	types := []ecs.ComponentType{ecs.TypeOf[position](), ecs.TypeOf[velocity](), ecs.TypeOf[health]()}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ecs.HasAll(&entity, types...)
+	}
+}
+
+// BenchmarkHasAllPools checks for the same components by looking them up in their pools,
+// for comparison with BenchmarkHasAll.
+func BenchmarkHasAllPools(b *testing.B) {
+	_, entity := newHasScene()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, okP := ecs.TryGet[position](&entity)
+		_, okV := ecs.TryGet[velocity](&entity)
+		_, okH := ecs.TryGet[health](&entity)
+		_ = okP && okV && okH
+	}
+}
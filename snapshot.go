@@ -0,0 +1,651 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// The snapshot format starts with a header, followed by sections:
+//
+//	header:     magic, version
+//	entities:   count, (generation, alive, children count, child indices...) per entity
+//	free:       count, indices of free entities
+//	components: type count, (name, count, entity indices..., values) per type
+//	relations:  type count, (name, count, (source, target) indices..., values) per type
+//	resources:  count, (name, value) per resource
+//
+// Numbers are unsigned varints, and names and values are prefixed with their length.
+const (
+	snapshotMagic   = "ECSS"
+	snapshotVersion = 1
+)
+
+var errCorruptSnapshot = errors.New("ecs: snapshot is truncated or corrupt")
+
+// Snapshot is a scene encoded by Scene.Snapshot.
+type Snapshot []byte
+
+// snapshotContents is a decoded snapshot.
+type snapshotContents struct {
+	entities   []snapshotEntity
+	free       []uint32
+	components []componentBlock
+	relations  []relationBlock
+	resources  []resourceBlock
+}
+
+type snapshotEntity struct {
+	generation uint32
+	alive      bool
+	children   []uint32
+}
+
+// componentBlock is all components of a type.
+type componentBlock struct {
+	codec   typeCodec
+	indices []uint32 // entity indices
+	values  any      // []T
+}
+
+// relationBlock is all relations of a type.
+type relationBlock struct {
+	codec   relationCodec
+	indices []uint32 // source and target index of each relation
+	values  any      // []R
+}
+
+type resourceBlock struct {
+	codec  typeCodec
+	values any // []T with one element
+}
+
+// Snapshot encodes the whole scene in a compact binary format: the entities with their
+// IDs, their registered components, relations and children, and the registered
+// resources. Components, relations and resources of types which are not registered with
+// RegisterComponent or RegisterRelation are skipped.
+//
+// Values are encoded with their MarshalBinary method if they implement
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, with encoding/binary if
+// they have a fixed size and only exported fields, and otherwise with encoding/gob.
+func (scene *Scene) Snapshot() (Snapshot, error) {
+	return scene.contents().encode()
+}
+
+// Restore replaces the entities of the scene with the entities in the snapshot, which
+// keep their IDs. All entities are removed before the snapshot is restored, so the
+// OnRemove and OnAdd hooks are called. Resources of registered types are replaced by the
+// resources in the snapshot, while other resources and the systems are kept. An error is
+// returned, and the scene is not changed, if the snapshot is corrupt, has an unsupported
+// version or contains unregistered type names.
+func (scene *Scene) Restore(snapshot Snapshot) error {
+	if err := scene.structuralError(); err != nil {
+		return err
+	}
+	contents, err := decodeSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	for index := range scene.entities {
+		// Descendants are removed with their parents, so the record is checked every time.
+		if scene.entities[index].alive {
+			entity := scene.entityAt(uint32(index))
+			scene.removeEntity(&entity)
+		}
+	}
+	for _, codec := range registeredTypes() {
+		codec.removeResource(scene)
+	}
+
+	scene.entities = make([]entityRecord, len(contents.entities))
+	for index, entity := range contents.entities {
+		scene.entities[index].generation = entity.generation
+		scene.entities[index].alive = entity.alive
+	}
+	scene.freeEntities = contents.free
+	for parent, entity := range contents.entities {
+		for _, child := range entity.children {
+			record := &scene.entities[parent]
+			record.children = append(record.children, newEntityID(child, scene.entities[child].generation))
+			scene.entities[child].parent = newEntityID(uint32(parent), record.generation)
+		}
+	}
+
+	for _, block := range contents.components {
+		block.codec.addComponents(scene, block.indices, block.values)
+	}
+	for _, block := range contents.relations {
+		for i := 0; i < len(block.indices); i += 2 {
+			source, target := scene.entityAt(block.indices[i]), scene.entityAt(block.indices[i+1])
+			block.codec.addRelation(&source, &target, block.codec.value(block.values, i/2))
+		}
+	}
+	for _, block := range contents.resources {
+		block.codec.setResource(scene, block.codec.value(block.values, 0))
+	}
+	return nil
+}
+
+// entityAt returns the entity at the index, which must be alive.
+func (scene *Scene) entityAt(index uint32) Entity {
+	return Entity{newEntityID(index, scene.entities[index].generation), scene}
+}
+
+// id returns the ID of the entity at the index in the snapshot.
+func (contents *snapshotContents) id(index uint32) EntityID {
+	return newEntityID(index, contents.entities[index].generation)
+}
+
+func (scene *Scene) contents() *snapshotContents {
+	contents := &snapshotContents{
+		entities: make([]snapshotEntity, len(scene.entities)),
+		free:     scene.freeEntities,
+	}
+	for index, record := range scene.entities {
+		entity := &contents.entities[index]
+		entity.generation, entity.alive = record.generation, record.alive
+		for _, child := range record.children {
+			entity.children = append(entity.children, child.Index())
+		}
+	}
+
+	for _, codec := range registeredTypes() {
+		if indices, values := codec.components(scene); len(indices) > 0 {
+			contents.components = append(contents.components, componentBlock{codec, indices, values})
+		}
+		if values := codec.resourceValues(scene); values != nil {
+			contents.resources = append(contents.resources, resourceBlock{codec, values})
+		}
+	}
+	for _, codec := range registeredRelations() {
+		if indices, values := codec.allRelations(scene); len(indices) > 0 {
+			contents.relations = append(contents.relations, relationBlock{codec, indices, values})
+		}
+	}
+	return contents
+}
+
+func (contents *snapshotContents) encode() (Snapshot, error) {
+	w := &snapshotWriter{}
+	w.buffer = append(w.buffer, snapshotMagic...)
+	w.uvarint(snapshotVersion)
+
+	w.uvarint(uint64(len(contents.entities)))
+	for _, entity := range contents.entities {
+		w.uvarint(uint64(entity.generation))
+		if !entity.alive {
+			w.uvarint(0)
+			continue
+		}
+		w.uvarint(1)
+		w.indices(entity.children)
+	}
+	w.indices(contents.free)
+
+	w.uvarint(uint64(len(contents.components)))
+	for _, block := range contents.components {
+		w.string(block.codec.typeName())
+		w.indices(block.indices)
+		if err := block.codec.writeValues(w, block.values); err != nil {
+			return nil, fmt.Errorf("ecs: encoding component %q: %w", block.codec.typeName(), err)
+		}
+	}
+	w.uvarint(uint64(len(contents.relations)))
+	for _, block := range contents.relations {
+		w.string(block.codec.typeName())
+		w.indices(block.indices)
+		if err := block.codec.writeValues(w, block.values); err != nil {
+			return nil, fmt.Errorf("ecs: encoding relation %q: %w", block.codec.typeName(), err)
+		}
+	}
+	w.uvarint(uint64(len(contents.resources)))
+	for _, block := range contents.resources {
+		w.string(block.codec.typeName())
+		if err := block.codec.writeValues(w, block.values); err != nil {
+			return nil, fmt.Errorf("ecs: encoding resource %q: %w", block.codec.typeName(), err)
+		}
+	}
+	return w.buffer, nil
+}
+
+// validHierarchy returns true if every entity is the child of at most one entity, and no
+// entity is its own ancestor. Restoring other hierarchies would make removing the
+// entities recurse forever.
+func validHierarchy(entities []snapshotEntity) bool {
+	parents := newParents(len(entities))
+	for parent, entity := range entities {
+		for _, child := range entity.children {
+			if parents[child] != -1 {
+				return false
+			}
+			parents[child] = parent
+		}
+	}
+	return acyclic(parents)
+}
+
+// decodeSnapshot decodes and validates the snapshot.
+func decodeSnapshot(snapshot Snapshot) (*snapshotContents, error) {
+	if !bytes.HasPrefix(snapshot, []byte(snapshotMagic)) {
+		return nil, errors.New("ecs: data is not a snapshot")
+	}
+	r := &snapshotReader{data: snapshot[len(snapshotMagic):]}
+	if version := r.uvarint(); r.err == nil && version != snapshotVersion {
+		return nil, fmt.Errorf("ecs: unsupported snapshot version %d", version)
+	}
+
+	contents := &snapshotContents{entities: make([]snapshotEntity, r.count())}
+	for i := range contents.entities {
+		entity := &contents.entities[i]
+		entity.generation = uint32(r.uvarint())
+		if entity.alive = r.uvarint() == 1; entity.alive {
+			entity.children = r.indices()
+		}
+	}
+	contents.free = r.indices()
+	if r.err != nil {
+		return nil, r.err
+	}
+	alive := func(indices ...uint32) bool {
+		for _, index := range indices {
+			if index >= uint32(len(contents.entities)) || !contents.entities[index].alive {
+				return false
+			}
+		}
+		return true
+	}
+	for _, entity := range contents.entities {
+		if !alive(entity.children...) {
+			return nil, errCorruptSnapshot
+		}
+	}
+	if !validHierarchy(contents.entities) {
+		return nil, errCorruptSnapshot
+	}
+	// Every dead entity is free exactly once, so that NewEntity never gives out an index
+	// twice.
+	free := make([]bool, len(contents.entities))
+	for _, index := range contents.free {
+		if index >= uint32(len(contents.entities)) || contents.entities[index].alive || free[index] {
+			return nil, errCorruptSnapshot
+		}
+		free[index] = true
+	}
+	for index, entity := range contents.entities {
+		if !entity.alive && !free[index] {
+			return nil, errCorruptSnapshot
+		}
+	}
+
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		codec, err := lookupType(r.string())
+		if err != nil {
+			return nil, err
+		}
+		block := componentBlock{codec: codec, indices: r.indices()}
+		if r.err == nil && !alive(block.indices...) {
+			return nil, errCorruptSnapshot
+		}
+		if block.values, err = codec.readValues(r, len(block.indices)); err != nil {
+			return nil, fmt.Errorf("ecs: decoding component %q: %w", codec.typeName(), err)
+		}
+		contents.components = append(contents.components, block)
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		codec, err := lookupRelation(r.string())
+		if err != nil {
+			return nil, err
+		}
+		block := relationBlock{codec: codec, indices: r.indices()}
+		if r.err == nil && (len(block.indices)%2 != 0 || !alive(block.indices...)) {
+			return nil, errCorruptSnapshot
+		}
+		if block.values, err = codec.readValues(r, len(block.indices)/2); err != nil {
+			return nil, fmt.Errorf("ecs: decoding relation %q: %w", codec.typeName(), err)
+		}
+		contents.relations = append(contents.relations, block)
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		codec, err := lookupType(r.string())
+		if err != nil {
+			return nil, err
+		}
+		block := resourceBlock{codec: codec}
+		if block.values, err = codec.readValues(r, 1); err != nil {
+			return nil, fmt.Errorf("ecs: decoding resource %q: %w", codec.typeName(), err)
+		}
+		contents.resources = append(contents.resources, block)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return contents, nil
+}
+
+func (codec componentCodec[T]) components(scene *Scene) ([]uint32, any) {
+	componentPool, ok := findPool[T](scene)
+	if !ok {
+		return nil, nil
+	}
+	var indices []uint32
+	var values []T
+	for _, chunk := range componentPool.chunks([]uint32{componentPool.componentID()}) {
+		for i := range chunk {
+			indices = append(indices, chunk[i].entity.id.Index())
+			values = append(values, chunk[i].component)
+		}
+	}
+	return indices, values
+}
+
+func (codec componentCodec[T]) addComponents(scene *Scene, indices []uint32, values any) {
+	componentPool := getPool[T](scene)
+	for i, value := range values.([]T) {
+		entity := scene.entityAt(indices[i])
+		componentPool.add(&entity, &value)
+	}
+}
+
+func (codec componentCodec[T]) addComponent(entity *Entity, value any) {
+	component := value.(T)
+	getPool[T](entity.scene).add(entity, &component)
+}
+
+func (codec componentCodec[T]) removeComponent(entity *Entity) {
+	getPool[T](entity.scene).remove(entity)
+}
+
+func (codec componentCodec[T]) resourceValues(scene *Scene) any {
+	if res, ok := Resource[T](scene); ok {
+		return []T{*res}
+	}
+	return nil
+}
+
+func (codec componentCodec[T]) setResource(scene *Scene, value any) {
+	res := value.(T)
+	SetResource(scene, &res)
+}
+
+func (codec componentCodec[T]) removeResource(scene *Scene) {
+	RemoveResource[T](scene)
+}
+
+func (codec relationCodecOf[R]) allRelations(scene *Scene) ([]uint32, any) {
+	relationPool, ok := findPool[relation[R]](scene)
+	if !ok {
+		return nil, nil
+	}
+	var indices []uint32
+	var values []R
+	for _, chunk := range relationPool.chunks([]uint32{relationPool.componentID()}) {
+		for i := range chunk {
+			source := chunk[i].entity.id.Index()
+			for _, pair := range chunk[i].component.pairs {
+				indices = append(indices, source, pair.target.Index())
+				values = append(values, pair.data)
+			}
+		}
+	}
+	return indices, values
+}
+
+func (codec relationCodecOf[R]) addRelation(source, target *Entity, value any) {
+	data := value.(R)
+	AddRelation(source, target, &data)
+}
+
+func (codec relationCodecOf[R]) removeRelation(source, target *Entity) {
+	RemoveRelation[R](source, target)
+}
+
+// valueCodec encodes values of a registered type in snapshots and deltas. The values
+// are passed as a []T.
+type valueCodec interface {
+	writeValues(w *snapshotWriter, values any) error
+	readValues(r *snapshotReader, n int) (any, error)
+	// value returns element i of values as a T.
+	value(values any, i int) any
+	// valueSlice converts a slice of T values to a []T.
+	valueSlice(values []any) any
+	// checkValue returns an error if value is not a T.
+	checkValue(value any) error
+}
+
+type valuesOf[T any] struct {
+	format binaryFormat
+}
+
+func (codec valuesOf[T]) writeValues(w *snapshotWriter, values any) error {
+	return encodeValues(w, codec.format, values.([]T))
+}
+
+func (codec valuesOf[T]) readValues(r *snapshotReader, n int) (any, error) {
+	return decodeValues[T](r, codec.format, n)
+}
+
+func (codec valuesOf[T]) value(values any, i int) any {
+	return values.([]T)[i]
+}
+
+func (codec valuesOf[T]) checkValue(value any) error {
+	if _, ok := value.(T); !ok {
+		return fmt.Errorf("ecs: value of type %T is not a %s", value, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return nil
+}
+
+func (codec valuesOf[T]) valueSlice(values []any) any {
+	result := make([]T, len(values))
+	for i, value := range values {
+		result[i] = value.(T)
+	}
+	return result
+}
+
+// binaryFormat is how values of a type are encoded in snapshots.
+type binaryFormat int
+
+const (
+	formatFixed     binaryFormat = iota // encoding/binary
+	formatMarshaler                     // encoding.BinaryMarshaler
+	formatGob                           // encoding/gob
+)
+
+func binaryFormatOf[T any]() binaryFormat {
+	value := new(T)
+	_, marshaler := any(value).(encoding.BinaryMarshaler)
+	_, unmarshaler := any(value).(encoding.BinaryUnmarshaler)
+	if marshaler && unmarshaler {
+		return formatMarshaler
+	}
+	if binary.Size(value) >= 0 && exportedOnly(reflect.TypeOf(value).Elem()) {
+		return formatFixed
+	}
+	return formatGob
+}
+
+// exportedOnly returns true if all struct fields in the type are exported, which is
+// required by encoding/binary.
+func exportedOnly(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return exportedOnly(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || !exportedOnly(field.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func encodeValues[T any](w *snapshotWriter, format binaryFormat, values []T) error {
+	switch format {
+	case formatMarshaler:
+		for i := range values {
+			data, err := any(&values[i]).(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				return err
+			}
+			w.bytes(data)
+		}
+		return nil
+	case formatFixed:
+		var buffer bytes.Buffer
+		if err := binary.Write(&buffer, binary.LittleEndian, values); err != nil {
+			return err
+		}
+		w.bytes(buffer.Bytes())
+		return nil
+	default:
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode(values); err != nil {
+			return err
+		}
+		w.bytes(buffer.Bytes())
+		return nil
+	}
+}
+
+func decodeValues[T any](r *snapshotReader, format binaryFormat, n int) ([]T, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	values := make([]T, n)
+	switch format {
+	case formatMarshaler:
+		for i := range values {
+			data := r.bytes()
+			if r.err != nil {
+				return nil, r.err
+			}
+			if err := any(&values[i]).(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+		}
+	case formatFixed:
+		data := r.bytes()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, values); err != nil {
+			return nil, err
+		}
+	default:
+		data := r.bytes()
+		if r.err != nil {
+			return nil, r.err
+		}
+		values = values[:0]
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+			return nil, err
+		}
+		if len(values) != n {
+			return nil, errCorruptSnapshot
+		}
+	}
+	return values, nil
+}
+
+type snapshotWriter struct {
+	buffer []byte
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	w.buffer = append(w.buffer, scratch[:n]...)
+}
+
+func (w *snapshotWriter) bytes(data []byte) {
+	w.uvarint(uint64(len(data)))
+	w.buffer = append(w.buffer, data...)
+}
+
+func (w *snapshotWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buffer = append(w.buffer, s...)
+}
+
+func (w *snapshotWriter) indices(indices []uint32) {
+	w.uvarint(uint64(len(indices)))
+	for _, index := range indices {
+		w.uvarint(uint64(index))
+	}
+}
+
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errCorruptSnapshot
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads the number of items which follow. Every item takes at least one byte, so
+// larger counts are rejected before anything is allocated for them.
+func (r *snapshotReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = errCorruptSnapshot
+		return 0
+	}
+	return int(n)
+}
+
+func (r *snapshotReader) bytes() []byte {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = errCorruptSnapshot
+	}
+	if r.err != nil {
+		return nil
+	}
+	data := r.data[:n]
+	r.data = r.data[n:]
+	return data
+}
+
+func (r *snapshotReader) indices() []uint32 {
+	indices := make([]uint32, r.count())
+	for i := range indices {
+		indices[i] = uint32(r.uvarint())
+	}
+	return indices
+}
+
+func (r *snapshotReader) string() string {
+	return string(r.bytes())
+}
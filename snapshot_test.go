@@ -0,0 +1,129 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+// color is encoded with its own MarshalBinary method.
+type color struct {
+	r, g, b uint8
+}
+
+func (c *color) MarshalBinary() ([]byte, error) {
+	return []byte{c.r, c.g, c.b}, nil
+}
+
+func (c *color) UnmarshalBinary(data []byte) error {
+	if len(data) != 3 {
+		return errors.New("invalid color")
+	}
+	c.r, c.g, c.b = data[0], data[1], data[2]
+	return nil
+}
+
+func init() {
+	ecs.RegisterComponent[color]("color")
+}
+
+func TestSnapshot(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := newSaveScene(storage)
+		parent, a, b := findByName(scene, "parent"), findByName(scene, "a"), findByName(scene, "b")
+		ecs.AddComponent(&a, &color{r: 1, g: 2, b: 3})
+		spare := scene.NewEntity()
+		spare.Remove()
+
+		snapshot, err := scene.Snapshot()
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+
+		// Changes after the snapshot are rolled back by Restore.
+		added := scene.NewEntity()
+		ecs.AddComponent(&a, &transform{X: 5})
+		b.Remove()
+		ecs.SetResource(scene, &settings{Volume: 0})
+
+		err = scene.Restore(snapshot)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(b.ID())), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(added.ID())), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(findByName(scene, "b")), subx.CompareEqual(b)))
+		t.Run("Expected correct result", subx.Test(subx.Value(parent.Children()), subx.DeepEqual([]ecs.Entity{b, a})))
+
+		tf, _ := ecs.GetComponent[transform](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(*tf), subx.CompareEqual(transform{X: 1, Y: 2})))
+		c, _ := ecs.GetComponent[color](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(*c), subx.CompareEqual(color{r: 1, g: 2, b: 3})))
+		rel, _ := ecs.GetRelation[follows](&b, &a)
+		t.Run("Expected correct result", subx.Test(subx.Value(rel.Distance), subx.CompareEqual(3.0)))
+		res, _ := ecs.Resource[settings](scene)
+		t.Run("Expected correct result", subx.Test(subx.Value(res.Volume), subx.CompareEqual(7)))
+
+		// The free entities are restored, so new entities get the same IDs.
+		t.Run("Expected correct result", subx.Test(subx.Value(scene.NewEntity()), subx.CompareEqual(added)))
+
+		// Restoring into another scene gives the same entities.
+		other := ecs.NewScene(storage)
+		err = other.Restore(snapshot)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(other.Alive(parent.ID())), subx.CompareEqual(true)))
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	scene := newSaveScene(ecs.PoolStorage)
+	snapshot, _ := scene.Snapshot()
+	a := findByName(scene, "a")
+
+	for _, data := range [][]byte{
+		nil,
+		[]byte("ECSS\x02"),
+		snapshot[:len(snapshot)-1],
+		snapshot[:len(snapshot)/2],
+		// Entities which are their own child, children of each other, and a child of two
+		// entities.
+		[]byte("ECSS\x01\x01\x00\x01\x01\x00\x00\x00\x00\x00"),
+		[]byte("ECSS\x01\x02\x00\x01\x01\x01\x00\x01\x01\x00\x00\x00\x00\x00"),
+		[]byte("ECSS\x01\x03\x00\x01\x01\x02\x00\x01\x01\x02\x00\x01\x00\x00\x00\x00\x00"),
+		// A dead entity which is free twice, and one which is not free.
+		[]byte("ECSS\x01\x01\x01\x00\x02\x00\x00\x00\x00\x00"),
+		[]byte("ECSS\x01\x01\x01\x00\x00\x00\x00\x00"),
+	} {
+		err := scene.Restore(data)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+	}
+	// Nothing is changed when restoring fails.
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.Alive(a.ID())), subx.CompareEqual(true)))
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	scene := ecs.Scene{}
+	for n := 0; n < 100000; n++ {
+		entity := scene.NewEntity()
+		ecs.AddComponent(&entity, &transform{X: float64(n)})
+		ecs.AddComponent(&entity, &color{})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot, _ := scene.Snapshot()
+		scene.Restore(snapshot)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stage is a part of Scene.Update. All systems in a stage are updated before the
+// systems in the next stage.
+type Stage int
+
+const (
+	// StagePreUpdate is updated first, once per Scene.Update.
+	StagePreUpdate Stage = iota
+	// StageFixedUpdate is updated zero or more times per Scene.Update, with the fixed
+	// timestep as delta time. See Scene.SetFixedTimestep.
+	StageFixedUpdate
+	// StageUpdate is the default stage of systems, updated once per Scene.Update.
+	StageUpdate
+	// StagePostUpdate is updated once per Scene.Update, after StageUpdate.
+	StagePostUpdate
+	// StageRender is updated last, once per Scene.Update.
+	StageRender
+
+	stageCount = iota
+)
+
+const (
+	defaultFixedTimestep = 1.0 / 60
+	defaultMaxSubsteps   = 5
+)
+
+func (stage Stage) String() string {
+	switch stage {
+	case StagePreUpdate:
+		return "PreUpdate"
+	case StageFixedUpdate:
+		return "FixedUpdate"
+	case StageUpdate:
+		return "Update"
+	case StagePostUpdate:
+		return "PostUpdate"
+	case StageRender:
+		return "Render"
+	}
+	return fmt.Sprintf("Stage(%d)", int(stage))
+}
+
+// InStage adds the system to the stage instead of StageUpdate. Systems can only be
+// ordered with Before and After relative to systems in the same stage.
+func InStage(stage Stage) SystemOption {
+	return func(entry *systemEntry) {
+		entry.stage = stage
+	}
+}
+
+// SetFixedTimestep sets the delta time of StageFixedUpdate, and the maximum number of
+// times StageFixedUpdate is updated per Scene.Update. Time exceeding the maximum number
+// of steps is dropped, so that a slow frame does not make the next frames slower. The
+// default is 1/60 seconds and 5 steps.
+func (scene *Scene) SetFixedTimestep(step float64, maxSubsteps int) {
+	scene.fixedTimestep = step
+	scene.maxSubsteps = maxSubsteps
+}
+
+// Alpha returns how far the time is between the last and the next fixed update, as a
+// fraction of the fixed timestep. It is used to interpolate between fixed updates when
+// rendering.
+func (scene *Scene) Alpha() float64 {
+	return scene.alpha
+}
+
+// updateFixed updates StageFixedUpdate once for every fixed timestep in the accumulated time.
+func (scene *Scene) updateFixed(dt float64, workers int) {
+	step, maxSubsteps := scene.fixedTimestep, scene.maxSubsteps
+	if step <= 0 {
+		step = defaultFixedTimestep
+	}
+	if maxSubsteps <= 0 {
+		maxSubsteps = defaultMaxSubsteps
+	}
+
+	scene.accumulator += dt
+	for steps := 0; scene.accumulator >= step; steps++ {
+		if steps == maxSubsteps {
+			scene.accumulator = math.Mod(scene.accumulator, step)
+			break
+		}
+		scene.updateStage(StageFixedUpdate, step, workers)
+		scene.accumulator -= step
+	}
+	scene.alpha = scene.accumulator / step
+}
+
+// scheduleSystems orders the systems in each stage, and splits them into batches which
+// can be updated in parallel.
+func scheduleSystems(systems []*systemEntry) ([]*systemEntry, [stageCount][][]*systemEntry, error) {
+	var order []*systemEntry
+	var batches [stageCount][][]*systemEntry
+	for stage := Stage(0); stage < stageCount; stage++ {
+		var stageSystems []*systemEntry
+		for _, entry := range systems {
+			if entry.stage == stage {
+				stageSystems = append(stageSystems, entry)
+			}
+		}
+		stageOrder, err := sortSystems(stageSystems)
+		if err != nil {
+			return nil, batches, err
+		}
+		order = append(order, stageOrder...)
+		batches[stage] = batchSystems(stageOrder)
+	}
+	return order, batches, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type dtSystem struct {
+	ecs.System
+	name  string
+	order *[]string
+	dts   []float64
+	alpha float64
+}
+
+func (sys *dtSystem) Update(dt float64) {
+	*sys.order = append(*sys.order, sys.name)
+	sys.dts = append(sys.dts, dt)
+	sys.alpha = sys.Scene().Alpha()
+}
+
+func TestStages(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	render := &dtSystem{name: "render", order: &order}
+	update := &dtSystem{name: "update", order: &order}
+	pre := &dtSystem{name: "pre", order: &order}
+	post := &dtSystem{name: "post", order: &order}
+
+	scene.AddSystem(render, ecs.InStage(ecs.StageRender))
+	scene.AddSystem(update)
+	scene.AddSystem(post, ecs.InStage(ecs.StagePostUpdate), ecs.Before("update"))
+	scene.AddSystem(pre, ecs.InStage(ecs.StagePreUpdate))
+	scene.Update(0.5)
+
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"pre", "update", "post", "render"})))
+	t.Run("Expected correct result", subx.Test(subx.Value(render.dts), subx.DeepEqual([]float64{0.5})))
+
+	err := scene.AddSystem(&dtSystem{order: &order}, ecs.InStage(ecs.Stage(10)))
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+}
+
+func TestFixedUpdate(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+	scene.SetFixedTimestep(0.25, 3)
+
+	fixed := &dtSystem{name: "fixed", order: &order}
+	render := &dtSystem{name: "render", order: &order}
+	scene.AddSystem(render, ecs.InStage(ecs.StageRender))
+	scene.AddSystem(fixed, ecs.InStage(ecs.StageFixedUpdate))
+
+	scene.Update(0.125)
+	t.Run("Expected correct result", subx.Test(subx.Value(len(fixed.dts)), subx.CompareEqual(0)))
+	t.Run("Expected correct result", subx.Test(subx.Value(render.alpha), subx.CompareEqual(0.5)))
+
+	scene.Update(0.5)
+	t.Run("Expected correct result", subx.Test(subx.Value(fixed.dts), subx.DeepEqual([]float64{0.25, 0.25})))
+	t.Run("Expected correct result", subx.Test(subx.Value(render.alpha), subx.CompareEqual(0.5)))
+
+	// The steps are clamped to 3, and the remaining time is dropped.
+	scene.Update(2)
+	t.Run("Expected correct result", subx.Test(subx.Value(len(fixed.dts)), subx.CompareEqual(5)))
+	t.Run("Expected correct result", subx.Test(subx.Value(render.alpha), subx.CompareEqual(0.5)))
+	t.Run("Expected correct result", subx.Test(subx.Value(order[len(order)-1]), subx.CompareEqual("render")))
+}
@@ -16,23 +16,40 @@ package ecs
 
 // System is the base struct for systems, and should be embedded by all systems.
 type System struct {
-	scene *Scene
+	scene    *Scene
+	commands *CommandBuffer
+	lastRun  uint32 // tick of the last update
 }
 
 func (system *System) Scene() *Scene {
 	return system.scene
 }
 
+// Commands returns the command buffer of the system, which is flushed after every Update.
+func (system *System) Commands() *CommandBuffer {
+	if system.commands == nil {
+		system.commands = NewCommandBuffer(system.scene)
+	}
+	return system.commands
+}
+
 func (system *System) setScene(scene *Scene) {
 	system.scene = scene
+	system.commands = NewCommandBuffer(scene)
+}
+
+func (system *System) base() *System {
+	return system
 }
 
 // SystemInterface is the interface that all systems have to implement.
 type SystemInterface interface {
 	Update(dt float64)
 
-	Scene() *Scene   // implemented by ecs.System
-	setScene(*Scene) // implemented by ecs.System
+	Scene() *Scene            // implemented by ecs.System
+	Commands() *CommandBuffer // implemented by ecs.System
+	setScene(*Scene)          // implemented by ecs.System
+	base() *System            // implemented by ecs.System
 }
 
 // InitListener is the interface for systems that has an Init function.
@@ -15,6 +15,7 @@
 package ecs_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/oyberntzen/ecs"
@@ -79,3 +80,165 @@ func TestSystemDelete(t *testing.T) {
 	t.Run("Expected correct result", subx.Test(subx.Value(sys2.deleted), subx.CompareEqual(true)))
 
 }
+
+type orderSystem struct {
+	ecs.System
+	name  string
+	order *[]string
+}
+
+func (sys *orderSystem) Update(dt float64) {
+	*sys.order = append(*sys.order, sys.name)
+}
+
+func TestSystemOrder(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	render := &orderSystem{name: "render", order: &order}
+	physics := &orderSystem{name: "physics", order: &order}
+	input := &orderSystem{name: "input", order: &order}
+	other := &orderSystem{name: "other", order: &order}
+
+	scene.AddSystem(render, ecs.Label("render"), ecs.After("physics"))
+	scene.AddSystem(other)
+	scene.AddSystem(physics, ecs.Label("physics"))
+	scene.AddSystem(input, ecs.Before("physics"))
+	scene.Update(0)
+
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"other", "input", "physics", "render"})))
+	t.Run("Expected correct result", subx.Test(subx.Value(len(scene.SystemOrder())), subx.CompareEqual(4)))
+	t.Run("Expected correct result", subx.Test(subx.Value(scene.SystemOrder()[0]), subx.CompareEqual[ecs.SystemInterface](other)))
+}
+
+func TestSystemOrderCycle(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	err := scene.AddSystem(&orderSystem{name: "a", order: &order}, ecs.Label("a"), ecs.After("b"))
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	err = scene.AddSystem(&orderSystem{name: "b", order: &order}, ecs.Label("b"), ecs.After("a"))
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"a"})))
+}
+
+func TestPrintSystemOrder(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	scene.AddSystem(&orderSystem{name: "b", order: &order}, ecs.Label("b"))
+	scene.AddSystem(&system1{}, ecs.Before("b"))
+
+	var builder strings.Builder
+	err := scene.PrintSystemOrder(&builder)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	t.Run("Expected correct result", subx.Test(subx.Value(builder.String()), subx.CompareEqual("1: Update *ecs_test.system1\n2: Update *ecs_test.orderSystem [b]\n")))
+}
+
+type removingSystem struct {
+	ecs.System
+	name   string
+	order  *[]string
+	remove ecs.SystemInterface
+}
+
+func (sys *removingSystem) Update(dt float64) {
+	*sys.order = append(*sys.order, sys.name)
+	sys.Scene().RemoveSystem(sys.remove)
+}
+
+func TestRemoveSystem(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	a := &orderSystem{name: "a", order: &order}
+	b := &orderSystem{name: "b", order: &order}
+	scene.AddSystem(a)
+	scene.AddSystem(b, ecs.Label("b"))
+	scene.AddSystem(&removingSystem{name: "c", order: &order, remove: b}, ecs.Before("b"))
+
+	err := scene.RemoveSystem(a)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	err = scene.RemoveSystem(a)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+	scene.Update(0)
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"c", "c"})))
+	t.Run("Expected correct result", subx.Test(subx.Value(len(scene.SystemOrder())), subx.CompareEqual(1)))
+}
+
+func TestAddSystemTwice(t *testing.T) {
+	scene := ecs.Scene{}
+	sys := &system1{}
+
+	err := scene.AddSystem(sys)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+	err = scene.AddSystem(sys)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+}
+
+func TestSetSystemEnabled(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	a := &orderSystem{name: "a", order: &order}
+	b := &orderSystem{name: "b", order: &order}
+	scene.AddSystem(a)
+	scene.AddSystem(b)
+
+	scene.SetSystemEnabled(a, false)
+	scene.Update(0)
+	scene.SetSystemEnabled(a, true)
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"b", "a", "b"})))
+
+	err := scene.SetSystemEnabled(&system1{}, false)
+	t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+}
+
+func TestSetGroupEnabled(t *testing.T) {
+	scene := ecs.Scene{}
+	order := []string{}
+
+	scene.AddSystem(&orderSystem{name: "a", order: &order}, ecs.InGroup("gameplay"))
+	scene.AddSystem(&orderSystem{name: "b", order: &order}, ecs.InGroup("gameplay", "ai"))
+	scene.AddSystem(&orderSystem{name: "c", order: &order})
+
+	scene.SetGroupEnabled("gameplay", false)
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"c"})))
+
+	order = order[:0]
+	scene.SetGroupEnabled("gameplay", true)
+	scene.SetGroupEnabled("ai", false)
+	scene.Update(0)
+	t.Run("Expected correct result", subx.Test(subx.Value(order), subx.DeepEqual([]string{"a", "c"})))
+}
+
+func TestSystemInitDeleteAfterInit(t *testing.T) {
+	scene := ecs.Scene{}
+
+	before := &system2{}
+	scene.AddSystem(before)
+	t.Run("Expected correct result", subx.Test(subx.Value(before.inited), subx.CompareEqual(false)))
+
+	scene.Init()
+	after := &system2{}
+	scene.AddSystem(after)
+	t.Run("Expected correct result", subx.Test(subx.Value(after.inited), subx.CompareEqual(true)))
+
+	scene.RemoveSystem(after)
+	t.Run("Expected correct result", subx.Test(subx.Value(after.deleted), subx.CompareEqual(true)))
+
+	scene.Delete()
+	t.Run("Expected correct result", subx.Test(subx.Value(before.deleted), subx.CompareEqual(true)))
+
+	removed := &system2{}
+	scene.AddSystem(removed)
+	scene.RemoveSystem(removed)
+	t.Run("Expected correct result", subx.Test(subx.Value(removed.inited), subx.CompareEqual(false)))
+	t.Run("Expected correct result", subx.Test(subx.Value(removed.deleted), subx.CompareEqual(false)))
+}
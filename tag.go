@@ -0,0 +1,180 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// tagPool stores the components of a zero-size type, a tag, in a scene using either
+// storage. Which entities have the tag is stored only in the entity signatures, so adding
+// and removing a tag sets a bit and does not move the entity to another archetype.
+type tagPool[T any] struct {
+	id      uint32
+	scene   *Scene
+	added   []uint32 // entity index -> tick when the tag was added
+	hooks   componentHooks[T]
+	removed removalLog
+}
+
+// isTag returns true if T is a zero-size type, which is stored in a tagPool.
+func isTag[T any]() bool {
+	return reflect.TypeOf((*T)(nil)).Elem().Size() == 0
+}
+
+func (p *tagPool[T]) componentID() uint32 {
+	return p.id
+}
+
+func (p *tagPool[T]) componentHooks() *componentHooks[T] {
+	return &p.hooks
+}
+
+func (p *tagPool[T]) removals() *removalLog {
+	return &p.removed
+}
+
+func (p *tagPool[T]) capture(entity *Entity) PrefabComponent {
+	return capture[T](p, entity)
+}
+
+func (p *tagPool[T]) has(entity *Entity) bool {
+	return p.scene.entities[entity.id.Index()].signature.has(p.id)
+}
+
+func (p *tagPool[T]) add(entity *Entity, data *T) {
+	if p.has(entity) {
+		p.hooks.set(p.component(entity))
+		return
+	}
+	index := entity.id.Index()
+	for index >= uint32(len(p.added)) {
+		p.added = append(p.added, 0)
+	}
+	p.added[index] = p.scene.currentTick()
+	p.scene.entities[index].signature.set(p.id)
+	if len(p.hooks.onAdd) > 0 {
+		p.hooks.added(p.component(entity))
+	}
+}
+
+func (p *tagPool[T]) get(entity *Entity) *T {
+	if !p.has(entity) {
+		return nil
+	}
+	return new(T) // does not allocate, since T has zero size
+}
+
+// component returns a component with the tick when the tag was added. Tags have no data
+// to change, so they are only changed when they are added.
+func (p *tagPool[T]) component(entity *Entity) *Component[T] {
+	if !p.has(entity) {
+		return nil
+	}
+	tick := p.added[entity.id.Index()]
+	return &Component[T]{entity: *entity, added: tick, changed: tick}
+}
+
+// chunks returns a single chunk with the tags of all entities.
+func (p *tagPool[T]) chunks(ids []uint32) [][]Component[T] {
+	var components []Component[T]
+	for index := range p.scene.entities {
+		if p.scene.entities[index].signature.has(p.id) {
+			tick := p.added[index]
+			components = append(components, Component[T]{entity: p.scene.entityAt(uint32(index)), added: tick, changed: tick})
+		}
+	}
+	return [][]Component[T]{components}
+}
+
+func (p *tagPool[T]) remove(entity *Entity) bool {
+	if !p.has(entity) {
+		return false
+	}
+	p.scene.entities[entity.id.Index()].signature.clear(p.id)
+	p.removed.add(*entity)
+	if len(p.hooks.onRemove) > 0 {
+		p.hooks.removed(&Component[T]{entity: *entity})
+	}
+	return true
+}
+
+// tagError returns an error if T is not a zero-size type.
+func tagError[T any]() error {
+	if !isTag[T]() {
+		return fmt.Errorf("ecs: %s is not a tag, tags must be zero-size types like struct{}", reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return nil
+}
+
+// AddTag adds the tag of type T to the entity. Tags are zero-size types, like struct{},
+// which are stored as bits in the entity signature instead of in a component pool. Tags
+// are components, so AddComponent, Has and the With and Without filters work with them
+// as well. An error is returned if T is not zero-size or if the entity is deleted.
+func AddTag[T any](entity *Entity) error {
+	if err := tagError[T](); err != nil {
+		return err
+	}
+	return AddComponent(entity, new(T))
+}
+
+// RemoveTag removes the tag of type T from the entity. A *ComponentError is returned if
+// the entity does not have the tag, and ErrEntityDead if the entity is deleted.
+func RemoveTag[T any](entity *Entity) error {
+	return RemoveComponent[T](entity)
+}
+
+// HasTag returns true if the entity has the tag of type T.
+func HasTag[T any](entity *Entity) bool {
+	return Has[T](entity)
+}
+
+// WithTag returns a filter only passing entities with the tag of type T. It is the same
+// filter as With.
+func WithTag[T any]() Filter {
+	return With[T]()
+}
+
+// WithoutTag returns a filter only passing entities without the tag of type T. It is the
+// same filter as Without.
+func WithoutTag[T any]() Filter {
+	return Without[T]()
+}
+
+// SetPrefabTag sets the tag of type T in the prefab.
+func SetPrefabTag[T any](prefab *Prefab) error {
+	if err := tagError[T](); err != nil {
+		return err
+	}
+	SetPrefabComponent(prefab, new(T))
+	return nil
+}
+
+// DeferAddTag records adding the tag of type T to the entity.
+func DeferAddTag[T any](buffer *CommandBuffer, entity *Entity) {
+	resolve := buffer.resolve(entity)
+	buffer.record(func() {
+		AddTag[T](resolve())
+	})
+}
+
+// DeferRemoveTag records removing the tag of type T from the entity.
+func DeferRemoveTag[T any](buffer *CommandBuffer, entity *Entity) {
+	resolve := buffer.resolve(entity)
+	buffer.record(func() {
+		RemoveTag[T](resolve())
+	})
+}
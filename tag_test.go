@@ -0,0 +1,225 @@
+// Copyright 2022 Øystein Berntzen
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// 	http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/oyberntzen/ecs"
+	"github.com/smyrman/subx"
+)
+
+type player struct{}
+
+type dead struct{}
+
+// Boss is a tag which is saved with the scene.
+type boss struct{}
+
+func init() {
+	ecs.RegisterComponent[boss]("boss")
+}
+
+func TestTag(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		a, b := scene.NewEntity(), scene.NewEntity()
+
+		err := ecs.AddTag[player](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[player](&a)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[player](&b)), subx.CompareEqual(false)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[dead](&a)), subx.CompareEqual(false)))
+
+		err = ecs.RemoveTag[player](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[player](&a)), subx.CompareEqual(false)))
+		err = ecs.RemoveTag[player](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrComponentMissing)), subx.CompareEqual(true)))
+
+		// Only zero-size types are tags.
+		err = ecs.AddTag[position](&a)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareNotEqual[error](nil)))
+
+		// Tags are removed with the entity, and not given to the next entity with the index.
+		ecs.AddTag[dead](&b)
+		b.Remove()
+		c := scene.NewEntity()
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[dead](&c)), subx.CompareEqual(false)))
+		err = ecs.AddTag[dead](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(errors.Is(err, ecs.ErrEntityDead)), subx.CompareEqual(true)))
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		for i := 0; i < 100; i++ {
+			entity := scene.NewEntity()
+			ecs.AddComponent(&entity, &health{hp: i})
+			if i%10 == 0 {
+				ecs.AddTag[player](&entity)
+			}
+		}
+
+		var players, others int
+		ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+			players++
+		}, ecs.WithTag[player]())
+		ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+			others++
+		}, ecs.WithoutTag[player]())
+		t.Run("Expected correct result", subx.Test(subx.Value(players), subx.CompareEqual(10)))
+		t.Run("Expected correct result", subx.Test(subx.Value(others), subx.CompareEqual(90)))
+
+		// Tags can be added in queries with a command buffer.
+		ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+			if h.hp < 50 {
+				ecs.DeferAddTag[dead](scene.Commands(), entity)
+			}
+		})
+		scene.Flush()
+		var deadCount int
+		ecs.Query1(scene, func(entity *ecs.Entity, h *health) {
+			deadCount++
+		}, ecs.WithTag[dead]())
+		t.Run("Expected correct result", subx.Test(subx.Value(deadCount), subx.CompareEqual(50)))
+	}
+}
+
+func TestTagComponent(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := ecs.NewScene(storage)
+		a, b := scene.NewEntity(), scene.NewEntity()
+		ecs.AddComponent(&a, &position{})
+		ecs.AddComponent(&b, &position{})
+
+		// Zero-size components are tags, and tags are components.
+		ecs.AddComponent(&a, &player{})
+		ecs.AddTag[dead](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[player](&a)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.Has[dead](&b)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasAll(&a, ecs.TypeOf[position](), ecs.TypeOf[player]())), subx.CompareEqual(true)))
+		_, err := ecs.GetComponent[dead](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+
+		var withPlayer, withoutPlayer, players []ecs.EntityID
+		ecs.Query1(scene, func(entity *ecs.Entity, p *position) {
+			withPlayer = append(withPlayer, entity.ID())
+		}, ecs.With[player]())
+		ecs.Query1(scene, func(entity *ecs.Entity, p *position) {
+			withoutPlayer = append(withoutPlayer, entity.ID())
+		}, ecs.Without[player]())
+		ecs.Query1(scene, func(entity *ecs.Entity, p *player) {
+			players = append(players, entity.ID())
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(withPlayer), subx.DeepEqual([]ecs.EntityID{a.ID()})))
+		t.Run("Expected correct result", subx.Test(subx.Value(withoutPlayer), subx.DeepEqual([]ecs.EntityID{b.ID()})))
+		t.Run("Expected correct result", subx.Test(subx.Value(players), subx.DeepEqual([]ecs.EntityID{a.ID()})))
+
+		// Tags do not move the entity to another archetype, so queries over components and
+		// tags visit the entities with both.
+		var both []ecs.EntityID
+		ecs.Query2(scene, func(entity *ecs.Entity, p *position, d *dead) {
+			both = append(both, entity.ID())
+		})
+		t.Run("Expected correct result", subx.Test(subx.Value(both), subx.DeepEqual([]ecs.EntityID{b.ID()})))
+
+		err = ecs.RemoveComponent[dead](&b)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[dead](&b)), subx.CompareEqual(false)))
+
+		// The tag hooks are called when the entity is removed.
+		var removed []ecs.EntityID
+		ecs.OnRemove(scene, func(entity ecs.Entity, p *player) {
+			removed = append(removed, entity.ID())
+		})
+		a.Remove()
+		t.Run("Expected correct result", subx.Test(subx.Value(removed), subx.DeepEqual([]ecs.EntityID{a.ID()})))
+	}
+}
+
+// bosses returns the IDs of the entities with the boss tag.
+func bosses(scene *ecs.Scene) []ecs.EntityID {
+	var ids []ecs.EntityID
+	ecs.Query1(scene, func(entity *ecs.Entity, tag *boss) {
+		ids = append(ids, entity.ID())
+	})
+	return ids
+}
+
+func TestTagSaved(t *testing.T) {
+	for _, storage := range []ecs.Storage{ecs.PoolStorage, ecs.ArchetypeStorage} {
+		scene := newSaveScene(storage)
+		a, b := findByName(scene, "a"), findByName(scene, "b")
+		ecs.AddTag[boss](&a)
+		ecs.AddTag[dead](&a) // Not registered
+
+		data, err := json.Marshal(scene)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		loaded := ecs.NewScene(storage)
+		err = json.Unmarshal(data, loaded)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		loadedA := findByName(loaded, "a")
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[boss](&loadedA)), subx.CompareEqual(true)))
+		t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[dead](&loadedA)), subx.CompareEqual(false)))
+
+		snapshot, err := scene.Snapshot()
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		restored := ecs.NewScene(storage)
+		err = restored.Restore(snapshot)
+		t.Run("Expected correct result", subx.Test(subx.Value(err), subx.CompareEqual[error](nil)))
+		t.Run("Expected correct result", subx.Test(subx.Value(bosses(restored)), subx.DeepEqual([]ecs.EntityID{a.ID()})))
+
+		// Moving the tag from a to b is sent in a delta.
+		ecs.RemoveTag[boss](&a)
+		ecs.AddTag[boss](&b)
+		next, _ := scene.Snapshot()
+		sendDelta(t, snapshot, next, restored)
+		t.Run("Expected correct result", subx.Test(subx.Value(bosses(restored)), subx.DeepEqual([]ecs.EntityID{b.ID()})))
+	}
+}
+
+func TestTagCopied(t *testing.T) {
+	scene := ecs.Scene{}
+	entity := scene.NewEntity()
+	ecs.AddTag[player](&entity)
+
+	clone, _ := scene.Clone(&entity)
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[player](&clone)), subx.CompareEqual(true)))
+
+	prefab := ecs.NewPrefab()
+	ecs.SetPrefabTag[dead](prefab)
+	instance, _ := scene.Instantiate(prefab)
+	t.Run("Expected correct result", subx.Test(subx.Value(ecs.HasTag[dead](&instance)), subx.CompareEqual(true)))
+}
+
+func BenchmarkTagToggle(b *testing.B) {
+	scene := ecs.Scene{}
+	entities := make([]ecs.Entity, 1000)
+	for i := range entities {
+		entities[i] = scene.NewEntity()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range entities {
+			ecs.AddTag[player](&entities[j])
+			ecs.RemoveTag[player](&entities[j])
+		}
+	}
+}